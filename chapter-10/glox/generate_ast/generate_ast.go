@@ -18,22 +18,32 @@ func GeneretaAst() {
 
 	defineAst(outdir, "Stmt", []string{
 		"Block      : statements []Stmt",
+		"Break      : keyword Token",
+		"Class      : name Token, methods []*Function",
+		"Continue   : keyword Token",
 		"Expression : expression Expr",
+		"Function   : name Token, params []Token, body []Stmt",
 		"If         : condition Expr, thenBranch Stmt," +
 			" elseBranch Stmt",
 		"Print      : expression Expr",
+		"Return     : keyword Token, value Expr",
 		"Var        : name Token, initializer Expr",
-		"While      : condition Expr, body Stmt",
+		"While      : keyword Token, condition Expr, body Stmt, increment Expr",
 	})
 
 	defineAst(outdir, "Expr", []string{
-		"Assign   : name Token, value Expr",
-		"Binary   : left Expr, operator Token, right Expr",
-		"Grouping : expression Expr",
-		"Literal  : value interface {}",
-		"Logical  : left Expr, operator Token, right Expr",
-		"Unary    : operator Token, right Expr",
-		"Variable : name Token",
+		"Assign        : name Token, value Expr",
+		"Binary        : left Expr, operator Token, right Expr",
+		"Call          : callee Expr, paren Token, arguments []Expr",
+		"Get           : object Expr, name Token",
+		"Grouping      : expression Expr",
+		"Interpolation : start Token, parts []Expr",
+		"Literal       : token Token, value Value",
+		"Logical       : left Expr, operator Token, right Expr",
+		"Set           : object Expr, name Token, value Expr",
+		"This          : keyword Token",
+		"Unary         : operator Token, right Expr",
+		"Variable      : name Token",
 	})
 }
 
@@ -48,7 +58,7 @@ func defineAst(outdir string, baseName string, types []string) {
 
 	// Create a bufio.Writer for efficient writing
 	writer := bufio.NewWriter(file)
-	writeContent(writer, fmt.Sprintf(`package main
+	writeContent(writer, fmt.Sprintf(`package lox
 	type %s interface {
 		Accept (v %sVisitor) (interface{},error)
 	}
@@ -57,7 +67,7 @@ func defineAst(outdir string, baseName string, types []string) {
 	writeLine(writer, fmt.Sprintf("type %sVisitor interface {", baseName))
 	for _, stype := range types {
 		substrings := strings.Split(stype, ":")
-		visitorFormatString := `Visit%s%s(%s %s) (interface{}, error)`
+		visitorFormatString := `Visit%s%s(%s *%s) (interface{}, error)`
 		className := strings.ReplaceAll(strings.Title(substrings[0]), " ", "")
 		//line := "visit" + className + baseName + "(" + strings.ToLower(baseName) + " " + className + " )"
 		line := fmt.Sprintf(visitorFormatString, className, baseName, strings.ToLower(baseName), className)
@@ -83,12 +93,12 @@ func defineType(writer *bufio.Writer, baseName string, className string, fields
     type %s struct {
 		%s
 	}
-	func New%s(%s) %s {
-		return %s{
+	func New%s(%s) *%s {
+		return &%s{
 			%s
 		}
 	}
-	func (a %s) Accept(visitor %sVisitor) (interface{}, error) {
+	func (a *%s) Accept(visitor %sVisitor) (interface{}, error) {
 		return visitor.Visit%s%s(a)
 	}
 	`
@@ -1,9 +1,6 @@
-package main
+package lox
 
-import (
-	"strconv"
-	"strings"
-)
+import "strings"
 
 type AstPrinter struct {
 }
@@ -18,34 +15,41 @@ func (a AstPrinter) Print(expr Expr) (string, error) {
 	}
 	return r.(string), nil
 }
-func (a AstPrinter) VisitBinaryExpr(expr Binary) (interface{}, error) {
+func (a AstPrinter) VisitBinaryExpr(expr *Binary) (interface{}, error) {
 	return parenthesize(expr.operator.Lexeme, expr.left, expr.right)
 }
-func (a AstPrinter) VisitGroupingExpr(expr Grouping) (interface{}, error) {
+func (a AstPrinter) VisitCallExpr(expr *Call) (interface{}, error) {
+	return parenthesize("call", append([]Expr{expr.callee}, expr.arguments...)...)
+}
+func (a AstPrinter) VisitGetExpr(expr *Get) (interface{}, error) {
+	return parenthesize("get "+expr.name.Lexeme, expr.object)
+}
+func (a AstPrinter) VisitSetExpr(expr *Set) (interface{}, error) {
+	return parenthesize("set "+expr.name.Lexeme, expr.object, expr.value)
+}
+func (a AstPrinter) VisitThisExpr(expr *This) (interface{}, error) {
+	return "this", nil
+}
+func (a AstPrinter) VisitGroupingExpr(expr *Grouping) (interface{}, error) {
 	return parenthesize("group", expr.expression)
 }
 
-func (a AstPrinter) VisitAssignExpr(expr Assign) (interface{}, error) {
-	return nil, nil
+func (a AstPrinter) VisitAssignExpr(expr *Assign) (interface{}, error) {
+	return parenthesize("= "+expr.name.Lexeme, expr.value)
 }
-func (a AstPrinter) VisitVariableExpr(expr Variable) (interface{}, error) {
-	return nil, nil
+func (a AstPrinter) VisitVariableExpr(expr *Variable) (interface{}, error) {
+	return expr.name.Lexeme, nil
 }
-func (a AstPrinter) VisitLogicalExpr(expr Logical) (interface{}, error) {
-	return nil, nil
+func (a AstPrinter) VisitLogicalExpr(expr *Logical) (interface{}, error) {
+	return parenthesize(expr.operator.Lexeme, expr.left, expr.right)
 }
-func (a AstPrinter) VisitLiteralExpr(expr Literal) (interface{}, error) {
-	if expr.value == nil {
-		return "nil", nil
-	}
-	switch v := expr.value.(type) {
-	case float64:
-		return strconv.FormatFloat(v, 'f', -1, 64), nil
-	default:
-		return expr.value.(string), nil
-	}
+func (a AstPrinter) VisitInterpolationExpr(expr *Interpolation) (interface{}, error) {
+	return parenthesize("interpolate", expr.parts...)
+}
+func (a AstPrinter) VisitLiteralExpr(expr *Literal) (interface{}, error) {
+	return expr.value.String(), nil
 }
-func (a AstPrinter) VisitUnaryExpr(expr Unary) (interface{}, error) {
+func (a AstPrinter) VisitUnaryExpr(expr *Unary) (interface{}, error) {
 	return parenthesize(expr.operator.Lexeme, expr.right)
 }
 
@@ -0,0 +1,33 @@
+package lox
+
+import "testing"
+
+func TestAstPrinter(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"1 + 2 * -x", "(+ 1 (* 2 (- x)))"},
+		{"(a)", "(group a)"},
+		{"a = b or c and d", "(= a (or b (and c d)))"},
+		{"o.f(1, nil).g = this", "(set g (call (get f o) 1 nil) this)"},
+		{`"a ${b} c"`, "(interpolate a  b  c)"},
+	}
+	for _, test := range tests {
+		collector := NewDiagnosticCollector("")
+		scanner := NewScanner(test.source, collector)
+		parser := NewParser(scanner.ScanTokens(), collector)
+		expr := parser.ParseExpression()
+		if collector.HadError() {
+			t.Errorf("%q: %v", test.source, collector.Diagnostics)
+			continue
+		}
+		got, err := NewAstPrinter().Print(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("%q printed as %s, want %s", test.source, got, test.want)
+		}
+	}
+}
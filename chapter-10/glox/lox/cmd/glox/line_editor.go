@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"glox/lox"
+)
+
+// errInterrupted is returned by ReadLine when Ctrl-C abandons the line.
+var errInterrupted = errors.New("interrupted")
+
+// Keys the line editor acts on.
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// lineEditor reads a line from a terminal in raw mode, echoing and
+// editing it itself. It keeps to a single row: a line wider than the
+// terminal scrolls sideways.
+//
+//	Left, Right, Ctrl-B, Ctrl-F   move by a character
+//	Home, End, Ctrl-A, Ctrl-E     move to the start or end
+//	Backspace, Delete, Ctrl-D     delete a character
+//	Ctrl-W, Ctrl-U, Ctrl-K        delete the word before, everything before
+//	                              or everything after the cursor
+//	Up, Down, Ctrl-P, Ctrl-N      step through history
+//	Ctrl-R                        search history backwards
+//	Tab                           complete the word before the cursor
+//	Ctrl-L                        clear the screen
+//	Ctrl-C                        abandon the line
+type lineEditor struct {
+	in  *bufio.Reader
+	out io.Writer
+	// width is the number of columns of the terminal.
+	width func() int
+	// history holds earlier lines, oldest first. A line spanning several
+	// is recalled with its line breaks turned into spaces.
+	history []string
+	// complete lists the words that could complete prefix.
+	complete func(prefix string) []string
+
+	prompt string
+	line   []rune
+	cursor int
+}
+
+// ReadLine reads one line after writing prompt. It returns io.EOF when
+// Ctrl-D is pressed on an empty line and errInterrupted after Ctrl-C.
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	e.prompt = prompt
+	e.line = e.line[:0]
+	e.cursor = 0
+	// historyIndex is the entry being shown; len(e.history) is the line
+	// being typed, which is kept in draft while browsing.
+	historyIndex := len(e.history)
+	var draft []rune
+	e.refresh()
+
+	for {
+		key, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch key {
+		case keyEnter, '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(e.line), nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.delete()
+		case keyBackspace, keyCtrlH:
+			if e.cursor > 0 {
+				e.cursor--
+				e.delete()
+			}
+		case keyCtrlA:
+			e.cursor = 0
+		case keyCtrlE:
+			e.cursor = len(e.line)
+		case keyCtrlB:
+			e.moveLeft()
+		case keyCtrlF:
+			e.moveRight()
+		case keyCtrlK:
+			e.line = e.line[:e.cursor]
+		case keyCtrlU:
+			e.line = append(e.line[:0], e.line[e.cursor:]...)
+			e.cursor = 0
+		case keyCtrlW:
+			start := e.cursor
+			for start > 0 && e.line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.line[start-1] != ' ' {
+				start--
+			}
+			e.line = append(e.line[:start], e.line[e.cursor:]...)
+			e.cursor = start
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case keyCtrlP:
+			historyIndex, draft = e.recall(historyIndex, historyIndex-1, draft)
+		case keyCtrlN:
+			historyIndex, draft = e.recall(historyIndex, historyIndex+1, draft)
+		case keyCtrlR:
+			line, submitted, err := e.search()
+			if err != nil {
+				return "", err
+			}
+			if submitted {
+				return line, nil
+			}
+		case keyTab:
+			e.completeWord()
+		case keyEscape:
+			switch e.escapeSequence() {
+			case "[A", "OA":
+				historyIndex, draft = e.recall(historyIndex, historyIndex-1, draft)
+			case "[B", "OB":
+				historyIndex, draft = e.recall(historyIndex, historyIndex+1, draft)
+			case "[C", "OC":
+				e.moveRight()
+			case "[D", "OD":
+				e.moveLeft()
+			case "[H", "OH", "[1~", "[7~":
+				e.cursor = 0
+			case "[F", "OF", "[4~", "[8~":
+				e.cursor = len(e.line)
+			case "[3~":
+				e.delete()
+			}
+		default:
+			if unicode.IsPrint(key) {
+				e.insert(key)
+			}
+		}
+		e.refresh()
+	}
+}
+
+// AddHistory makes line the most recent history entry.
+func (e *lineEditor) AddHistory(line string) {
+	e.history = append(e.history, line)
+}
+
+func (e *lineEditor) insert(key rune) {
+	e.line = append(e.line, 0)
+	copy(e.line[e.cursor+1:], e.line[e.cursor:])
+	e.line[e.cursor] = key
+	e.cursor++
+}
+
+// delete removes the character under the cursor.
+func (e *lineEditor) delete() {
+	if e.cursor < len(e.line) {
+		e.line = append(e.line[:e.cursor], e.line[e.cursor+1:]...)
+	}
+}
+
+func (e *lineEditor) moveLeft() {
+	if e.cursor > 0 {
+		e.cursor--
+	}
+}
+
+func (e *lineEditor) moveRight() {
+	if e.cursor < len(e.line) {
+		e.cursor++
+	}
+}
+
+// recall replaces the line with history entry to, or with the draft once
+// to steps past the newest entry, and returns the new index and draft.
+func (e *lineEditor) recall(from int, to int, draft []rune) (int, []rune) {
+	if to < 0 || to > len(e.history) {
+		return from, draft
+	}
+	if from == len(e.history) {
+		draft = append([]rune(nil), e.line...)
+	}
+	if to == len(e.history) {
+		e.line = append(e.line[:0], draft...)
+	} else {
+		e.line = []rune(strings.ReplaceAll(e.history[to], "\n", " "))
+	}
+	e.cursor = len(e.line)
+	return to, draft
+}
+
+// escapeSequence reads the rest of an escape sequence for a cursor key,
+// such as "[A" for Up or "[3~" for Delete.
+func (e *lineEditor) escapeSequence() string {
+	var sequence strings.Builder
+	for {
+		key, _, err := e.in.ReadRune()
+		if err != nil {
+			return sequence.String()
+		}
+		sequence.WriteRune(key)
+		// A sequence ends at its first letter or tilde after the opening
+		// bracket or O.
+		if sequence.Len() > 1 && (unicode.IsLetter(key) || key == '~') {
+			return sequence.String()
+		}
+		if sequence.Len() == 1 && key != '[' && key != 'O' {
+			return sequence.String()
+		}
+	}
+}
+
+// search runs an incremental reverse search through history, showing the
+// newest entry containing what has been typed. Ctrl-R again moves to an
+// older match, Enter submits the match, Ctrl-G or Ctrl-C gives up and
+// any other key leaves the match on the line to edit.
+func (e *lineEditor) search() (line string, submitted bool, err error) {
+	original := append([]rune(nil), e.line...)
+	originalCursor := e.cursor
+	var query []rune
+	match := len(e.history)
+	found := ""
+	// find looks for the query in entries older than before, leaving the
+	// last match in place when none has it.
+	find := func(before int) {
+		if len(query) == 0 {
+			match, found = len(e.history), ""
+			return
+		}
+		for index := before - 1; index >= 0; index-- {
+			if strings.Contains(e.history[index], string(query)) {
+				match = index
+				found = strings.ReplaceAll(e.history[index], "\n", " ")
+				return
+			}
+		}
+	}
+
+	for {
+		e.showSearch(string(query), found)
+		key, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", false, err
+		}
+		switch key {
+		case keyCtrlR:
+			find(match)
+		case keyBackspace, keyCtrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				match, found = len(e.history), ""
+				find(match)
+			}
+		case keyCtrlG, keyCtrlC:
+			e.line, e.cursor = original, originalCursor
+			return "", false, nil
+		case keyEnter, '\n':
+			fmt.Fprint(e.out, "\r\x1b[K"+e.prompt+found+"\r\n")
+			return found, true, nil
+		default:
+			if unicode.IsPrint(key) {
+				query = append(query, key)
+				// The current match may still contain the longer query.
+				if match < len(e.history) {
+					find(match + 1)
+				} else {
+					find(match)
+				}
+				continue
+			}
+			if key == keyEscape {
+				e.escapeSequence()
+			}
+			e.line = []rune(found)
+			e.cursor = len(e.line)
+			return "", false, nil
+		}
+	}
+}
+
+func (e *lineEditor) showSearch(query string, found string) {
+	prompt := fmt.Sprintf("(reverse-i-search)'%s': ", query)
+	e.draw(prompt, []rune(found), len([]rune(found)))
+}
+
+// completeWord completes the identifier before the cursor. A single
+// candidate is filled in; several are filled in as far as they agree and,
+// when that adds nothing, listed below the line.
+func (e *lineEditor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+	start := e.cursor
+	for start > 0 && lox.IsIdentifierPart(e.line[start-1]) {
+		start--
+	}
+	prefix := string(e.line[start:e.cursor])
+	candidates := e.complete(prefix)
+	if len(candidates) == 0 {
+		return
+	}
+	// Compare runes rather than bytes, so the common prefix never ends
+	// partway through a character.
+	common := []rune(candidates[0])
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, string(common)) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) > len([]rune(prefix)) {
+		for _, key := range common[len([]rune(prefix)):] {
+			e.insert(key)
+		}
+		return
+	}
+	if len(candidates) > 1 {
+		fmt.Fprint(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+	}
+}
+
+// refresh redraws the prompt and line.
+func (e *lineEditor) refresh() {
+	e.draw(e.prompt, e.line, e.cursor)
+}
+
+// draw writes prompt and as much of line around the cursor as fits on the
+// row, then puts the terminal's cursor at cursor.
+func (e *lineEditor) draw(prompt string, line []rune, cursor int) {
+	width := e.width()
+	promptWidth := len([]rune(prompt))
+	start := 0
+	for promptWidth+cursor-start >= width && start < cursor {
+		start++
+	}
+	end := len(line)
+	for promptWidth+end-start > width && end > cursor {
+		end--
+	}
+	var screen strings.Builder
+	screen.WriteString("\r" + prompt + string(line[start:end]) + "\x1b[K\r")
+	if column := promptWidth + cursor - start; column > 0 {
+		fmt.Fprintf(&screen, "\x1b[%dC", column)
+	}
+	io.WriteString(e.out, screen.String())
+}
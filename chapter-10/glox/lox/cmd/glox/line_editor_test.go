@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLineEditor(t *testing.T) {
+	history := []string{"print 1;", "var x = 2;", "fun f() {\n  return 3;\n}", "print 4;"}
+	complete := func(prefix string) []string {
+		var candidates []string
+		for _, word := range []string{"count", "counter", "print", "größe_1", "poké", "pokê"} {
+			if strings.HasPrefix(word, prefix) {
+				candidates = append(candidates, word)
+			}
+		}
+		return candidates
+	}
+	tests := []struct {
+		name  string
+		keys  string
+		want  string
+		error error
+	}{
+		{"typing", "print 1;\r", "print 1;", nil},
+		{"left and insert", "abc\x1b[D\x1b[DX\r", "aXbc", nil},
+		{"home and end", "bc\x01a\x05d\r", "abcd", nil},
+		{"backspace and delete", "abcd\x7f\x1b[D\x1b[D\x1b[3~\r", "ac", nil},
+		{"kill to end", "abcd\x02\x02\x0b\r", "ab", nil},
+		{"kill to start", "abcd\x02\x15\r", "d", nil},
+		{"delete word", "var x = 2\x17\x17\r", "var x ", nil},
+		{"history", "\x1b[A\x1b[A\r", "fun f() {   return 3; }", nil},
+		{"history keeps draft", "dr\x10\x0e\r", "dr", nil},
+		{"history stops at oldest", "\x1b[A\x1b[A\x1b[A\x1b[A\x1b[A\x1b[B\r", "var x = 2;", nil},
+		{"search", "\x12print\r", "print 4;", nil},
+		{"search older", "\x12print\x12\r", "print 1;", nil},
+		{"search then edit", "\x12var\x1b[C!\r", "var x = 2;!", nil},
+		{"search cancelled", "keep\x12var\x07\r", "keep", nil},
+		{"complete single", "pr\t 1;\r", "print 1;", nil},
+		{"complete common prefix", "c\t\r", "count", nil},
+		{"complete mid line", "(co) \x01\x06\x06\x06\t\r", "(count) ", nil},
+		{"complete unicode name", "1+grö\t\r", "1+größe_1", nil},
+		// é and ê share their first byte, which must not be inserted alone.
+		{"complete before differing runes", "pok\t\r", "pok", nil},
+		{"complete to differing runes", "po\t\r", "pok", nil},
+		{"interrupt", "abc\x03", "", errInterrupted},
+		{"end of input", "\x04", "", io.EOF},
+		{"utf-8", "\"é\x1b[Dü\r", "\"üé", nil},
+	}
+	for _, test := range tests {
+		editor := &lineEditor{
+			in:       bufio.NewReader(strings.NewReader(test.keys)),
+			out:      io.Discard,
+			width:    func() int { return 80 },
+			history:  history,
+			complete: complete,
+		}
+		got, err := editor.ReadLine("> ")
+		if got != test.want || err != test.error {
+			t.Errorf("%s: got %q, %v; want %q, %v", test.name, got, err, test.want, test.error)
+		}
+	}
+}
+
+func TestLineEditorScrollsLongLines(t *testing.T) {
+	var screen strings.Builder
+	editor := &lineEditor{out: &screen, width: func() int { return 10 }}
+	editor.draw("> ", []rune("0123456789"), 10)
+	// Only the end of the line fits next to the prompt, with the cursor in
+	// the last column.
+	want := "\r> 3456789\x1b[K\r\x1b[9C"
+	if screen.String() != want {
+		t.Errorf("drew %q, want %q", screen.String(), want)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"glox/lox"
+)
+
+var format = flag.String("format", "text", "diagnostic output format: text, json or sarif")
+var useVM = flag.Bool("vm", false, "compile to bytecode and run on the virtual machine")
+var dumpBytecode = flag.Bool("dump-bytecode", false, "print the compiled bytecode instead of running it")
+var gcStress = flag.Bool("gc-stress", false, "collect garbage before every allocation (implies -vm)")
+var gcLog = flag.Bool("gc-log", false, "log garbage collections and a summary to stderr (implies -vm)")
+
+// runner is the part of the API the tree-walking Interpreter and the VM
+// share.
+type runner interface {
+	Run(source string) (lox.Result, []lox.Diagnostic)
+	RunSource(file string, source string) (lox.Result, []lox.Diagnostic)
+	RunPrompt(source string) (lox.Result, []lox.Diagnostic)
+	SetOutput(w io.Writer)
+	SetInput(r io.Reader)
+}
+
+func newRunner() runner {
+	if *dumpBytecode {
+		return &bytecodeDumper{stdout: os.Stdout}
+	}
+	if *useVM || *gcStress || *gcLog {
+		vm := lox.NewVM()
+		vm.StressGC = *gcStress
+		if *gcLog {
+			vm.GCLog = os.Stderr
+		}
+		return vm
+	}
+	return lox.NewInterpreter()
+}
+
+// logGCStats prints the collector's totals when -gc-log asked for them.
+func logGCStats(r runner) {
+	vm, isVM := r.(*lox.VM)
+	if !isVM || !*gcLog {
+		return
+	}
+	stats := vm.GCStats()
+	fmt.Fprintf(os.Stderr, "gc: %d collections, %d bytes allocated, %d bytes in %d objects freed\n",
+		stats.Collections, stats.BytesAllocated, stats.BytesFreed, stats.ObjectsFreed)
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox [flags] [script]")
+		fmt.Fprintln(os.Stderr, "       glox [flags] test [path...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	length := len(args)
+
+	if length > 0 && args[0] == "test" {
+		runTests(args[1:])
+	} else if length > 1 {
+		flag.Usage()
+		os.Exit(64)
+	} else if length == 1 {
+		runFile(args[0])
+	} else {
+		runPrompt()
+	}
+}
+
+// bytecodeDumper disassembles source to stdout instead of running it.
+type bytecodeDumper struct {
+	stdout io.Writer
+}
+
+func (d *bytecodeDumper) Run(source string) (lox.Result, []lox.Diagnostic) {
+	return d.RunSource("", source)
+}
+
+func (d *bytecodeDumper) RunSource(file string, source string) (lox.Result, []lox.Diagnostic) {
+	return lox.DumpBytecode(d.stdout, file, source)
+}
+
+func (d *bytecodeDumper) RunPrompt(source string) (lox.Result, []lox.Diagnostic) {
+	return d.Run(source)
+}
+
+func (d *bytecodeDumper) SetOutput(w io.Writer) {
+	d.stdout = w
+}
+
+// SetInput does nothing: a dumped program never runs, so reads no input.
+func (d *bytecodeDumper) SetInput(r io.Reader) {}
+
+func runFile(filePath string) {
+	// Read the file content
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		log.Fatalf("Error reading file: %v", err)
+	}
+
+	runner := newRunner()
+	result, diagnostics := runner.RunSource(filePath, string(content))
+	report(diagnostics, string(content))
+	logGCStats(runner)
+	switch result {
+	case lox.INTERPRET_COMPILE_ERROR:
+		os.Exit(65)
+	case lox.INTERPRET_RUNTIME_ERROR:
+		os.Exit(70)
+	}
+}
+
+// runTests checks every .lox file under paths against its annotations,
+// each on a fresh runner, and reports the failures and a summary for each
+// directory, which is one per chapter.
+func runTests(paths []string) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	scripts := make(map[string][]string)
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(script string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Like the go tool, leave testdata alone unless asked for it by
+			// name: the scripts there are checked against golden files.
+			if entry.IsDir() && entry.Name() == "testdata" && script != path {
+				return filepath.SkipDir
+			}
+			if !entry.IsDir() && filepath.Ext(script) == ".lox" {
+				dir := filepath.Dir(script)
+				scripts[dir] = append(scripts[dir], script)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("Error finding tests: %v", err)
+		}
+	}
+	dirs := make([]string, 0, len(scripts))
+	for dir := range scripts {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	failed := 0
+	for _, dir := range dirs {
+		passed := 0
+		for _, script := range scripts[dir] {
+			content, err := os.ReadFile(script)
+			if err != nil {
+				log.Fatalf("Error reading file: %v", err)
+			}
+			failures := lox.CheckConformance(string(content), func(source string, stdout io.Writer) (lox.Result, []lox.Diagnostic) {
+				runner := newRunner()
+				runner.SetOutput(stdout)
+				return runner.RunSource(script, source)
+			})
+			if len(failures) == 0 {
+				passed++
+				continue
+			}
+			fmt.Printf("FAIL %s\n", script)
+			for _, failure := range failures {
+				fmt.Printf("  %s\n", failure)
+			}
+		}
+		failed += len(scripts[dir]) - passed
+		fmt.Printf("%s: %d passed, %d failed\n", dir, passed, len(scripts[dir])-passed)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// report renders diagnostics to stderr in the format chosen by -format.
+func report(diagnostics []lox.Diagnostic, source string) {
+	var err error
+	switch *format {
+	case "json":
+		err = lox.RenderJSON(os.Stderr, diagnostics)
+	case "sarif":
+		err = lox.RenderSARIF(os.Stderr, diagnostics, source)
+	default:
+		err = lox.RenderTextWithSource(os.Stderr, diagnostics, source)
+	}
+	if err != nil {
+		log.Fatalf("Error writing diagnostics: %v", err)
+	}
+}
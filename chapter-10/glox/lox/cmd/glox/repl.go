@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"glox/lox"
+)
+
+// historyLimit is how many entries of history the REPL keeps.
+const historyLimit = 1000
+
+// An entry spanning several lines is saved with its line breaks escaped,
+// so each entry takes one line of the history file.
+var (
+	historyEscaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	historyUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+)
+
+// history is every entry typed at the REPL, newest last, saved to a
+// dotfile so it outlives the session.
+type history struct {
+	path    string
+	entries []string
+}
+
+// historyPath is $GLOX_HISTORY or, failing that, ~/.glox_history. It is
+// empty when there is nowhere to keep history.
+func historyPath() string {
+	if path := os.Getenv("GLOX_HISTORY"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".glox_history")
+}
+
+func loadHistory(path string) *history {
+	h := &history{path: path}
+	if path == "" {
+		return h
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return h
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		if line != "" {
+			h.entries = append(h.entries, historyUnescaper.Replace(line))
+		}
+	}
+	if len(h.entries) > historyLimit {
+		// Entries are appended as they are typed, so this is the one place
+		// the file gets cut back down.
+		h.entries = h.entries[len(h.entries)-historyLimit:]
+		h.save()
+	}
+	return h
+}
+
+// save rewrites the history file with just the entries kept, replacing it
+// in one step so a failure leaves the old file intact.
+func (h *history) save() {
+	var content strings.Builder
+	for _, entry := range h.entries {
+		content.WriteString(historyEscaper.Replace(entry) + "\n")
+	}
+	temporary := h.path + ".tmp"
+	if err := os.WriteFile(temporary, []byte(content.String()), 0600); err != nil {
+		return
+	}
+	if err := os.Rename(temporary, h.path); err != nil {
+		os.Remove(temporary)
+	}
+}
+
+// add records entry and saves it. History is a convenience, so failing to
+// save it is not worth interrupting the session for.
+func (h *history) add(entry string) {
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > historyLimit {
+		h.entries = h.entries[1:]
+	}
+	if h.path == "" {
+		return
+	}
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, historyEscaper.Replace(entry))
+}
+
+// print lists the entries, numbered from the oldest kept.
+func (h *history) print() {
+	for index, entry := range h.entries {
+		fmt.Printf("%5d  %s\n", index+1, strings.ReplaceAll(entry, "\n", "\n       "))
+	}
+}
+
+// replUsage lists the REPL's meta-commands. Each starts with a colon and
+// takes the rest of the line as its argument.
+const replUsage = `:env           list the variables in scope and their values
+:ast <expr>    print the syntax tree of an expression
+:tokens <src>  print the tokens source scans to
+:load <file>   run a script, keeping what it defines
+:time <stmt>   run a statement and report how long it took
+:reset         start over with nothing defined
+:history       list the entries typed in this and earlier sessions
+:help          list these commands`
+
+// commandArguments names the argument of each meta-command that needs one.
+var commandArguments = map[string]string{
+	":ast":    "<expr>",
+	":tokens": "<src>",
+	":load":   "<file>",
+	":time":   "<stmt>",
+}
+
+// repl is the state of an interactive session.
+type repl struct {
+	runner  runner
+	history *history
+	lines   lineReader
+	// stdin is read both by lines and by the runner's readLine(), every
+	// runner the session starts included.
+	stdin *bufio.Reader
+}
+
+// lineReader reads the lines typed at the REPL.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+	AddHistory(line string)
+}
+
+// newLineReader returns a line editor when stdin is a terminal and plain
+// line reading otherwise, such as when input is piped in. Both read through
+// stdin, which the runner's readLine() shares, so a program reading input
+// takes the lines that follow the one that ran it.
+func newLineReader(r *repl) lineReader {
+	fd := int(os.Stdin.Fd())
+	if !isTerminal(fd) {
+		return &plainReader{in: r.stdin, out: os.Stdout}
+	}
+	return &terminalReader{
+		fd: fd,
+		editor: &lineEditor{
+			in:       r.stdin,
+			out:      os.Stdout,
+			width:    func() int { return terminalWidth(int(os.Stdout.Fd())) },
+			history:  append([]string(nil), r.history.entries...),
+			complete: r.complete,
+		},
+	}
+}
+
+type plainReader struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (p *plainReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(p.out, prompt)
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+func (p *plainReader) AddHistory(line string) {}
+
+// terminalReader runs a lineEditor with the terminal in raw mode for just
+// as long as it takes to read each line, so program output is unaffected.
+type terminalReader struct {
+	fd     int
+	editor *lineEditor
+}
+
+func (t *terminalReader) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(t.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	return t.editor.ReadLine(prompt)
+}
+
+func (t *terminalReader) AddHistory(line string) {
+	t.editor.AddHistory(line)
+}
+
+// runPrompt reads entries from stdin and runs each on the same runner, so
+// state persists between them. An entry that stops partway through a
+// statement continues on the next line, prompted with "...", until it is
+// complete or a blank line gives up on it. A line starting with a colon is
+// a meta-command; see replUsage.
+func runPrompt() {
+	r := &repl{
+		runner:  newRunner(),
+		history: loadHistory(historyPath()),
+		stdin:   bufio.NewReader(os.Stdin),
+	}
+	r.runner.SetInput(r.stdin)
+	r.lines = newLineReader(r)
+	var entry []string
+	for {
+		prompt := "> "
+		if len(entry) > 0 {
+			prompt = "... "
+		}
+		line, err := r.lines.ReadLine(prompt)
+		if err == errInterrupted {
+			entry = nil
+			continue
+		}
+		if err != nil {
+			break
+		}
+		if len(entry) == 0 {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" {
+				continue
+			}
+			if strings.HasPrefix(trimmed, ":") {
+				r.addHistory(trimmed)
+				r.command(trimmed)
+				continue
+			}
+		}
+		entry = append(entry, line)
+		source := strings.Join(entry, "\n")
+		if strings.TrimSpace(line) != "" && lox.IsIncomplete(source) {
+			continue
+		}
+		entry = nil
+		r.addHistory(source)
+		_, diagnostics := r.runner.RunPrompt(source)
+		report(diagnostics, source)
+	}
+	fmt.Println()
+	logGCStats(r.runner)
+}
+
+func (r *repl) addHistory(entry string) {
+	r.history.add(entry)
+	r.lines.AddHistory(entry)
+}
+
+// complete lists the keywords and names of globals that start with prefix.
+func (r *repl) complete(prefix string) []string {
+	if prefix == "" {
+		return nil
+	}
+	words := lox.Keywords()
+	if globals, hasGlobals := r.runner.(interface{ GlobalNames() []string }); hasGlobals {
+		words = append(words, globals.GlobalNames()...)
+	}
+	var candidates []string
+	seen := make(map[string]bool)
+	for _, word := range words {
+		if strings.HasPrefix(word, prefix) && !seen[word] {
+			seen[word] = true
+			candidates = append(candidates, word)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// command runs a meta-command line.
+func (r *repl) command(line string) {
+	name, argument, _ := strings.Cut(line, " ")
+	argument = strings.TrimSpace(argument)
+	if placeholder, needsArgument := commandArguments[name]; needsArgument && argument == "" {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s\n", name, placeholder)
+		return
+	}
+
+	switch name {
+	case ":env":
+		dumper, canDump := r.runner.(interface{ DumpEnvironment(w io.Writer) })
+		if !canDump {
+			fmt.Fprintln(os.Stderr, "No environment to show.")
+			return
+		}
+		dumper.DumpEnvironment(os.Stdout)
+	case ":ast":
+		collector := lox.NewDiagnosticCollector("")
+		scanner := lox.NewScanner(argument, collector)
+		parser := lox.NewParser(scanner.ScanTokens(), collector)
+		expr := parser.ParseExpression()
+		if collector.HadError() {
+			report(collector.Diagnostics, argument)
+			return
+		}
+		tree, err := lox.NewAstPrinter().Print(expr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		fmt.Println(tree)
+	case ":tokens":
+		collector := lox.NewDiagnosticCollector("")
+		scanner := lox.NewScanner(argument, collector)
+		for _, token := range scanner.ScanTokens() {
+			fmt.Printf("Type: %-12s Lexeme: %-10s Literal: %v\n", lox.TokenName[token.Type], token.Lexeme, token.Literal)
+		}
+		report(collector.Diagnostics, argument)
+	case ":load":
+		content, err := os.ReadFile(argument)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+			return
+		}
+		_, diagnostics := r.runner.RunSource(argument, string(content))
+		report(diagnostics, string(content))
+	case ":time":
+		start := time.Now()
+		_, diagnostics := r.runner.RunPrompt(argument)
+		elapsed := time.Since(start)
+		report(diagnostics, argument)
+		fmt.Printf("took %v\n", elapsed)
+	case ":reset":
+		logGCStats(r.runner)
+		r.runner = newRunner()
+		r.runner.SetInput(r.stdin)
+	case ":history":
+		r.history.print()
+	case ":help":
+		fmt.Println(replUsage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command '%s'. Type :help for a list.\n", name)
+	}
+}
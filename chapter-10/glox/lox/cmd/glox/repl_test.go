@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"glox/lox"
+)
+
+// TestPipedInputIsShared checks a program run at the REPL reads the lines
+// piped in after it, rather than the REPL running them as code.
+func TestPipedInputIsShared(t *testing.T) {
+	stdin := bufio.NewReader(strings.NewReader("var l = readLine();\nhello world\nprint l;\n"))
+	var output bytes.Buffer
+	interpreter := lox.NewInterpreter()
+	interpreter.SetOutput(&output)
+	interpreter.SetInput(stdin)
+	lines := &plainReader{in: stdin, out: io.Discard}
+	for {
+		line, err := lines.ReadLine("> ")
+		if err != nil {
+			break
+		}
+		if _, diagnostics := interpreter.RunPrompt(line); len(diagnostics) > 0 {
+			t.Errorf("%q: %v", line, diagnostics)
+		}
+	}
+	if output.String() != "hello world\n" {
+		t.Errorf("got output %q, want the line read by readLine()", output.String())
+	}
+}
+
+// TestResetKeepsSharedInput checks the runner :reset starts reads input
+// through the REPL's reader too.
+func TestResetKeepsSharedInput(t *testing.T) {
+	r := &repl{
+		runner:  newRunner(),
+		history: &history{},
+		stdin:   bufio.NewReader(strings.NewReader(":reset\nvar l = readLine();\nhello world\nprint l;\n")),
+	}
+	r.runner.SetInput(r.stdin)
+	r.lines = &plainReader{in: r.stdin, out: io.Discard}
+	var output bytes.Buffer
+	for {
+		line, err := r.lines.ReadLine("> ")
+		if err != nil {
+			break
+		}
+		if strings.HasPrefix(line, ":") {
+			r.command(line)
+			r.runner.SetOutput(&output)
+			continue
+		}
+		if _, diagnostics := r.runner.RunPrompt(line); len(diagnostics) > 0 {
+			t.Errorf("%q: %v", line, diagnostics)
+		}
+	}
+	if output.String() != "hello world\n" {
+		t.Errorf("got output %q, want the line read by readLine() after :reset", output.String())
+	}
+}
+
+func TestLoadHistoryTrimsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	var content strings.Builder
+	for n := 0; n < historyLimit+10; n++ {
+		fmt.Fprintf(&content, "print %d;\n", n)
+	}
+	// A multi-line entry is kept escaped.
+	content.WriteString(`{\n  print "last";\n}` + "\n")
+	if err := os.WriteFile(path, []byte(content.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := loadHistory(path)
+	if len(h.entries) != historyLimit || h.entries[0] != "print 11;" || h.entries[historyLimit-1] != "{\n  print \"last\";\n}" {
+		t.Fatalf("loaded %d entries from %q to %q, want the last %d", len(h.entries), h.entries[0], h.entries[len(h.entries)-1], historyLimit)
+	}
+	reloaded := loadHistory(path)
+	if !reflect.DeepEqual(reloaded.entries, h.entries) {
+		t.Errorf("file kept %d entries, want the %d loaded", len(reloaded.entries), historyLimit)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(saved), "\n"); lines != historyLimit {
+		t.Errorf("file has %d lines after loading, want %d", lines, historyLimit)
+	}
+
+	h.add("print 1000;")
+	if len(h.entries) != historyLimit || h.entries[historyLimit-1] != "print 1000;" {
+		t.Errorf("kept %d entries after adding one, want %d ending with the new one", len(h.entries), historyLimit)
+	}
+}
@@ -0,0 +1,55 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// isTerminal reports whether fd is a terminal the line editor can drive.
+func isTerminal(fd int) bool {
+	var termios syscall.Termios
+	return ioctl(fd, getTermios, unsafe.Pointer(&termios)) == nil
+}
+
+// makeRaw puts the terminal on fd into raw mode, so every key reaches the
+// line editor as it is pressed, and returns a function restoring it.
+func makeRaw(fd int) (func(), error) {
+	var original syscall.Termios
+	if err := ioctl(fd, getTermios, unsafe.Pointer(&original)); err != nil {
+		return nil, err
+	}
+	raw := original
+	raw.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INPCK | syscall.ISTRIP | syscall.IXON
+	raw.Cflag |= syscall.CS8
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.IEXTEN | syscall.ISIG
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, setTermios, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+	return func() {
+		ioctl(fd, setTermios, unsafe.Pointer(&original))
+	}, nil
+}
+
+// terminalWidth returns the number of columns of the terminal on fd, or 80
+// when it cannot tell.
+func terminalWidth(fd int) int {
+	var size struct {
+		rows, columns, xPixels, yPixels uint16
+	}
+	if ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&size)) != nil || size.columns == 0 {
+		return 80
+	}
+	return int(size.columns)
+}
+
+func ioctl(fd int, request uintptr, argument unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(argument))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
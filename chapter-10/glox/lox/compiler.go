@@ -0,0 +1,564 @@
+package lox
+
+import "math"
+
+// compilerLocal is a local variable held in a stack slot of the function
+// being compiled.
+type compilerLocal struct {
+	name       string
+	depth      int
+	isCaptured bool
+}
+
+// upvalueRef says where a closure finds a captured variable when it is
+// created: in a local slot of the enclosing function or in one of the
+// enclosing function's own upvalues.
+type upvalueRef struct {
+	index   int
+	isLocal bool
+}
+
+// loopState is what break and continue need from the innermost loop: the
+// scope depth to unwind to and the jumps still waiting for a target.
+type loopState struct {
+	enclosing  *loopState
+	scopeDepth int
+	breaks     []int
+	continues  []int
+}
+
+// functionState is the compiler state for a single function. Nested
+// declarations push a new one pointing back at the enclosing function.
+type functionState struct {
+	enclosing    *functionState
+	function     *vmFunction
+	functionType FunctionType
+	locals       []compilerLocal
+	upvalues     []upvalueRef
+	scopeDepth   int
+	loop         *loopState
+}
+
+// compiler turns a resolved syntax tree into bytecode for the VM. It relies
+// on the Resolver having already reported every scope error, and only
+// reports the limits of the bytecode format itself.
+type compiler struct {
+	current *functionState
+	// token is where in the source the code being emitted comes from.
+	token    Token
+	reporter ErrorReporter
+}
+
+// Statements and expressions set token before emitting, so each instruction
+// records the place in the source it was compiled from.
+func newCompiler(reporter ErrorReporter) *compiler {
+	return &compiler{token: Token{Line: 1}, reporter: reporter}
+}
+
+// compile returns the top-level script as a function taking no arguments.
+func (c *compiler) compile(statements []Stmt) *vmFunction {
+	c.beginFunction("", NONE)
+	for _, statement := range statements {
+		c.compileStmt(statement)
+	}
+	return c.endFunction()
+}
+
+func (c *compiler) error(message string) {
+	c.reporter.Report(newDiagnostic(COMPILE_ERROR, c.token.Line, message))
+}
+
+func (c *compiler) VisitBlockStmt(stmt *Block) (interface{}, error) {
+	c.beginScope()
+	for _, statement := range stmt.statements {
+		c.compileStmt(statement)
+	}
+	c.endScope()
+	return nil, nil
+}
+func (c *compiler) VisitBreakStmt(stmt *Break) (interface{}, error) {
+	c.token = stmt.keyword
+	loop := c.current.loop
+	c.discardLocals(loop.scopeDepth)
+	loop.breaks = append(loop.breaks, c.emitJump(OP_JUMP))
+	return nil, nil
+}
+func (c *compiler) VisitClassStmt(stmt *Class) (interface{}, error) {
+	c.token = stmt.name
+	name := c.identifierConstant(stmt.name.Lexeme)
+	c.emitOpShort(OP_CLASS, name)
+	c.defineVariable(stmt.name.Lexeme)
+
+	// Keep the class on the stack while its methods are attached.
+	c.emitGetVariable(stmt.name)
+	for _, method := range stmt.methods {
+		functionType := METHOD
+		if method.name.Lexeme == "init" {
+			functionType = INITIALIZER
+		}
+		c.function(method, functionType)
+		c.token = method.name
+		c.emitOpShort(OP_METHOD, c.identifierConstant(method.name.Lexeme))
+	}
+	c.emitOp(OP_POP)
+	return nil, nil
+}
+func (c *compiler) VisitContinueStmt(stmt *Continue) (interface{}, error) {
+	c.token = stmt.keyword
+	loop := c.current.loop
+	c.discardLocals(loop.scopeDepth)
+	loop.continues = append(loop.continues, c.emitJump(OP_JUMP))
+	return nil, nil
+}
+func (c *compiler) VisitExpressionStmt(stmt *Expression) (interface{}, error) {
+	c.compileExpr(stmt.expression)
+	c.emitOp(OP_POP)
+	return nil, nil
+}
+func (c *compiler) VisitFunctionStmt(stmt *Function) (interface{}, error) {
+	c.token = stmt.name
+	// A local function is in scope inside its own body so it can recurse.
+	if c.current.scopeDepth > 0 {
+		c.addLocal(stmt.name.Lexeme)
+		c.function(stmt, FUNCTION)
+		return nil, nil
+	}
+	c.function(stmt, FUNCTION)
+	c.defineVariable(stmt.name.Lexeme)
+	return nil, nil
+}
+func (c *compiler) VisitIfStmt(stmt *If) (interface{}, error) {
+	c.compileExpr(stmt.condition)
+	thenJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emitOp(OP_POP)
+	c.compileStmt(stmt.thenBranch)
+	elseJump := c.emitJump(OP_JUMP)
+	c.patchJump(thenJump)
+	c.emitOp(OP_POP)
+	if stmt.elseBranch != nil {
+		c.compileStmt(stmt.elseBranch)
+	}
+	c.patchJump(elseJump)
+	return nil, nil
+}
+func (c *compiler) VisitPrintStmt(stmt *Print) (interface{}, error) {
+	c.compileExpr(stmt.expression)
+	c.emitOp(OP_PRINT)
+	return nil, nil
+}
+func (c *compiler) VisitReturnStmt(stmt *Return) (interface{}, error) {
+	c.token = stmt.keyword
+	if stmt.value == nil {
+		c.emitReturn()
+		return nil, nil
+	}
+	c.compileExpr(stmt.value)
+	c.emitOp(OP_RETURN)
+	return nil, nil
+}
+func (c *compiler) VisitVarStmt(stmt *Var) (interface{}, error) {
+	c.token = stmt.name
+	if stmt.initializer != nil {
+		c.compileExpr(stmt.initializer)
+	} else {
+		c.emitOp(OP_NIL)
+	}
+	c.token = stmt.name
+	c.defineVariable(stmt.name.Lexeme)
+	return nil, nil
+}
+
+// VisitWhileStmt lays the loop out as
+//
+//	start:    condition
+//	          OP_JUMP_IF_FALSE exit
+//	          OP_POP
+//	          body
+//	continue: increment
+//	          OP_LOOP start
+//	exit:     OP_POP
+//	break:
+func (c *compiler) VisitWhileStmt(stmt *While) (interface{}, error) {
+	loopStart := len(c.chunk().Code)
+	c.compileExpr(stmt.condition)
+	exitJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emitOp(OP_POP)
+
+	loop := &loopState{enclosing: c.current.loop, scopeDepth: c.current.scopeDepth}
+	c.current.loop = loop
+	c.compileStmt(stmt.body)
+	c.current.loop = loop.enclosing
+
+	for _, jump := range loop.continues {
+		c.patchJump(jump)
+	}
+	if stmt.increment != nil {
+		c.compileExpr(stmt.increment)
+		c.emitOp(OP_POP)
+	}
+	c.emitLoop(loopStart)
+	c.patchJump(exitJump)
+	c.emitOp(OP_POP)
+	for _, jump := range loop.breaks {
+		c.patchJump(jump)
+	}
+	return nil, nil
+}
+
+func (c *compiler) VisitAssignExpr(expr *Assign) (interface{}, error) {
+	c.compileExpr(expr.value)
+	c.token = expr.name
+	if slot := c.current.resolveLocal(expr.name.Lexeme); slot != -1 {
+		c.emitOpByte(OP_SET_LOCAL, slot)
+	} else if index := c.resolveUpvalue(c.current, expr.name.Lexeme); index != -1 {
+		c.emitOpByte(OP_SET_UPVALUE, index)
+	} else {
+		c.emitOpShort(OP_SET_GLOBAL, c.identifierConstant(expr.name.Lexeme))
+	}
+	return nil, nil
+}
+func (c *compiler) VisitBinaryExpr(expr *Binary) (interface{}, error) {
+	c.compileExpr(expr.left)
+	c.compileExpr(expr.right)
+	c.token = expr.operator
+	switch expr.operator.Type {
+	case BANG_EQUAL:
+		c.emitOp(OP_EQUAL)
+		c.emitOp(OP_NOT)
+	case EQUAL_EQUAL:
+		c.emitOp(OP_EQUAL)
+	case GREATER:
+		c.emitOp(OP_GREATER)
+	case GREATER_EQUAL:
+		c.emitOp(OP_GREATER_EQUAL)
+	case LESS:
+		c.emitOp(OP_LESS)
+	case LESS_EQUAL:
+		c.emitOp(OP_LESS_EQUAL)
+	case PLUS:
+		c.emitOp(OP_ADD)
+	case MINUS:
+		c.emitOp(OP_SUBTRACT)
+	case STAR:
+		c.emitOp(OP_MULTIPLY)
+	case SLASH:
+		c.emitOp(OP_DIVIDE)
+	}
+	return nil, nil
+}
+func (c *compiler) VisitCallExpr(expr *Call) (interface{}, error) {
+	c.compileExpr(expr.callee)
+	for _, argument := range expr.arguments {
+		c.compileExpr(argument)
+	}
+	c.token = expr.paren
+	c.emitOpByte(OP_CALL, len(expr.arguments))
+	return nil, nil
+}
+func (c *compiler) VisitGetExpr(expr *Get) (interface{}, error) {
+	c.compileExpr(expr.object)
+	c.token = expr.name
+	c.emitOpShort(OP_GET_PROPERTY, c.identifierConstant(expr.name.Lexeme))
+	return nil, nil
+}
+func (c *compiler) VisitSetExpr(expr *Set) (interface{}, error) {
+	c.compileExpr(expr.object)
+	c.compileExpr(expr.value)
+	c.token = expr.name
+	c.emitOpShort(OP_SET_PROPERTY, c.identifierConstant(expr.name.Lexeme))
+	return nil, nil
+}
+func (c *compiler) VisitThisExpr(expr *This) (interface{}, error) {
+	c.emitGetVariable(expr.keyword)
+	return nil, nil
+}
+func (c *compiler) VisitGroupingExpr(expr *Grouping) (interface{}, error) {
+	c.compileExpr(expr.expression)
+	return nil, nil
+}
+func (c *compiler) VisitInterpolationExpr(expr *Interpolation) (interface{}, error) {
+	c.token = expr.start
+	for _, part := range expr.parts {
+		c.compileExpr(part)
+	}
+	c.emitOpByte(OP_INTERPOLATE, len(expr.parts))
+	return nil, nil
+}
+func (c *compiler) VisitLiteralExpr(expr *Literal) (interface{}, error) {
+	c.token = expr.token
+	switch {
+	case expr.value.IsNil():
+		c.emitOp(OP_NIL)
+	case expr.value.IsBool() && expr.value.AsBool():
+		c.emitOp(OP_TRUE)
+	case expr.value.IsBool():
+		c.emitOp(OP_FALSE)
+	default:
+		c.emitOpShort(OP_CONSTANT, c.makeConstant(expr.value))
+	}
+	return nil, nil
+}
+
+// VisitLogicalExpr leaves the left operand on the stack when it decides the
+// result and skips the right one.
+func (c *compiler) VisitLogicalExpr(expr *Logical) (interface{}, error) {
+	c.compileExpr(expr.left)
+	c.token = expr.operator
+	if expr.operator.Type == OR {
+		elseJump := c.emitJump(OP_JUMP_IF_FALSE)
+		endJump := c.emitJump(OP_JUMP)
+		c.patchJump(elseJump)
+		c.emitOp(OP_POP)
+		c.compileExpr(expr.right)
+		c.patchJump(endJump)
+		return nil, nil
+	}
+	endJump := c.emitJump(OP_JUMP_IF_FALSE)
+	c.emitOp(OP_POP)
+	c.compileExpr(expr.right)
+	c.patchJump(endJump)
+	return nil, nil
+}
+func (c *compiler) VisitUnaryExpr(expr *Unary) (interface{}, error) {
+	c.compileExpr(expr.right)
+	c.token = expr.operator
+	switch expr.operator.Type {
+	case BANG:
+		c.emitOp(OP_NOT)
+	case MINUS:
+		c.emitOp(OP_NEGATE)
+	}
+	return nil, nil
+}
+func (c *compiler) VisitVariableExpr(expr *Variable) (interface{}, error) {
+	c.emitGetVariable(expr.name)
+	return nil, nil
+}
+
+func (c *compiler) compileStmt(stmt Stmt) {
+	stmt.Accept(c)
+}
+
+func (c *compiler) compileExpr(expr Expr) {
+	expr.Accept(c)
+}
+
+// function compiles declaration into its own vmFunction and emits the
+// OP_CLOSURE that creates it at runtime, followed by one (isLocal, index)
+// pair per captured variable.
+func (c *compiler) function(declaration *Function, functionType FunctionType) {
+	c.beginFunction(declaration.name.Lexeme, functionType)
+	c.current.function.arity = len(declaration.params)
+	c.beginScope()
+	for _, param := range declaration.params {
+		c.token = param
+		c.addLocal(param.Lexeme)
+	}
+	for _, statement := range declaration.body {
+		c.compileStmt(statement)
+	}
+	upvalues := c.current.upvalues
+	function := c.endFunction()
+
+	c.token = declaration.name
+	c.emitOpShort(OP_CLOSURE, c.makeConstant(CallableValue(function)))
+	for _, upvalue := range upvalues {
+		isLocal := 0
+		if upvalue.isLocal {
+			isLocal = 1
+		}
+		c.emitByte(byte(isLocal))
+		c.emitByte(byte(upvalue.index))
+	}
+}
+
+// beginFunction starts compiling a new function. Slot 0 holds the callee
+// itself, or the receiver in methods, where it is reachable as "this".
+func (c *compiler) beginFunction(name string, functionType FunctionType) {
+	state := &functionState{
+		enclosing:    c.current,
+		function:     &vmFunction{name: name},
+		functionType: functionType,
+	}
+	slotZero := ""
+	if functionType == METHOD || functionType == INITIALIZER {
+		slotZero = "this"
+	}
+	state.locals = append(state.locals, compilerLocal{name: slotZero})
+	c.current = state
+}
+
+func (c *compiler) endFunction() *vmFunction {
+	c.emitReturn()
+	function := c.current.function
+	function.upvalueCount = len(c.current.upvalues)
+	c.current = c.current.enclosing
+	return function
+}
+
+func (c *compiler) beginScope() {
+	c.current.scopeDepth++
+}
+
+func (c *compiler) endScope() {
+	c.current.scopeDepth--
+	c.discardLocals(c.current.scopeDepth)
+	locals := c.current.locals
+	for len(locals) > 0 && locals[len(locals)-1].depth > c.current.scopeDepth {
+		locals = locals[:len(locals)-1]
+	}
+	c.current.locals = locals
+}
+
+// discardLocals emits the pops for every local deeper than depth, closing
+// the ones a closure captured. The locals stay declared, since break and
+// continue leave a scope only on one path through it.
+func (c *compiler) discardLocals(depth int) {
+	locals := c.current.locals
+	for i := len(locals) - 1; i >= 0 && locals[i].depth > depth; i-- {
+		if locals[i].isCaptured {
+			c.emitOp(OP_CLOSE_UPVALUE)
+		} else {
+			c.emitOp(OP_POP)
+		}
+	}
+}
+
+// defineVariable binds the value on top of the stack to name: locals simply
+// claim the slot the value already occupies.
+func (c *compiler) defineVariable(name string) {
+	if c.current.scopeDepth > 0 {
+		c.addLocal(name)
+		return
+	}
+	c.emitOpShort(OP_DEFINE_GLOBAL, c.identifierConstant(name))
+}
+
+func (c *compiler) addLocal(name string) {
+	if len(c.current.locals) > math.MaxUint8 {
+		c.error("Too many local variables in function.")
+		return
+	}
+	c.current.locals = append(c.current.locals, compilerLocal{name: name, depth: c.current.scopeDepth})
+}
+
+func (c *compiler) emitGetVariable(name Token) {
+	c.token = name
+	if slot := c.current.resolveLocal(name.Lexeme); slot != -1 {
+		c.emitOpByte(OP_GET_LOCAL, slot)
+	} else if index := c.resolveUpvalue(c.current, name.Lexeme); index != -1 {
+		c.emitOpByte(OP_GET_UPVALUE, index)
+	} else {
+		c.emitOpShort(OP_GET_GLOBAL, c.identifierConstant(name.Lexeme))
+	}
+}
+
+func (s *functionState) resolveLocal(name string) int {
+	for i := len(s.locals) - 1; i >= 0; i-- {
+		if s.locals[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// resolveUpvalue finds name in a function enclosing state and threads it
+// through the upvalues of every function in between.
+func (c *compiler) resolveUpvalue(state *functionState, name string) int {
+	if state.enclosing == nil {
+		return -1
+	}
+	if slot := state.enclosing.resolveLocal(name); slot != -1 {
+		state.enclosing.locals[slot].isCaptured = true
+		return c.addUpvalue(state, slot, true)
+	}
+	if index := c.resolveUpvalue(state.enclosing, name); index != -1 {
+		return c.addUpvalue(state, index, false)
+	}
+	return -1
+}
+
+func (c *compiler) addUpvalue(state *functionState, index int, isLocal bool) int {
+	for i, upvalue := range state.upvalues {
+		if upvalue.index == index && upvalue.isLocal == isLocal {
+			return i
+		}
+	}
+	if len(state.upvalues) > math.MaxUint8 {
+		c.error("Too many closure variables in function.")
+		return 0
+	}
+	state.upvalues = append(state.upvalues, upvalueRef{index: index, isLocal: isLocal})
+	return len(state.upvalues) - 1
+}
+
+func (c *compiler) chunk() *Chunk {
+	return &c.current.function.chunk
+}
+
+func (c *compiler) emitByte(b byte) {
+	c.chunk().Write(b, c.token)
+}
+
+func (c *compiler) emitOp(op OpCode) {
+	c.emitByte(byte(op))
+}
+
+func (c *compiler) emitOpByte(op OpCode, operand int) {
+	c.emitOp(op)
+	c.emitByte(byte(operand))
+}
+
+func (c *compiler) emitOpShort(op OpCode, operand int) {
+	c.emitOp(op)
+	c.emitByte(byte(operand >> 8))
+	c.emitByte(byte(operand))
+}
+
+// emitReturn emits the implicit return at the end of a function body.
+// Initializers always return the instance in slot 0.
+func (c *compiler) emitReturn() {
+	if c.current.functionType == INITIALIZER {
+		c.emitOpByte(OP_GET_LOCAL, 0)
+	} else {
+		c.emitOp(OP_NIL)
+	}
+	c.emitOp(OP_RETURN)
+}
+
+// emitJump emits op with a placeholder offset and returns where the offset
+// lives so patchJump can fill it in.
+func (c *compiler) emitJump(op OpCode) int {
+	c.emitOpShort(op, 0xffff)
+	return len(c.chunk().Code) - 2
+}
+
+func (c *compiler) patchJump(offset int) {
+	jump := len(c.chunk().Code) - offset - 2
+	if jump > math.MaxUint16 {
+		c.error("Too much code to jump over.")
+	}
+	c.chunk().Code[offset] = byte(jump >> 8)
+	c.chunk().Code[offset+1] = byte(jump)
+}
+
+func (c *compiler) emitLoop(loopStart int) {
+	offset := len(c.chunk().Code) - loopStart + 3
+	if offset > math.MaxUint16 {
+		c.error("Loop body too large.")
+	}
+	c.emitOpShort(OP_LOOP, offset)
+}
+
+func (c *compiler) makeConstant(value Value) int {
+	constant := c.chunk().AddConstant(value)
+	if constant > math.MaxUint16 {
+		c.error("Too many constants in one chunk.")
+		return 0
+	}
+	return constant
+}
+
+func (c *compiler) identifierConstant(name string) int {
+	return c.makeConstant(StringValue(name))
+}
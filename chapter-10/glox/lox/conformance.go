@@ -0,0 +1,124 @@
+package lox
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Expectation is one annotation in a conformance script and the line it
+// is on.
+type Expectation struct {
+	Line int
+	Text string
+}
+
+// Expectations is what the annotations in a conformance script say running
+// it should do:
+//
+//	print 1 + 2; // expect: 3
+//	print -"a"; // expect runtime error: Operand must be a number.
+//	var 1; // Error at '1': Expect variable name.
+//	// [line 7] Error at end: Expect ';' after value.
+//
+// An error annotation without a line number is expected on its own line.
+type Expectations struct {
+	Output []Expectation
+	// Errors holds each compile error in the text format.
+	Errors       []Expectation
+	RuntimeError *Expectation
+}
+
+var (
+	expectOutputPattern       = regexp.MustCompile(`// expect: ?(.*)`)
+	expectRuntimeErrorPattern = regexp.MustCompile(`// expect runtime error: (.+)`)
+	expectErrorPattern        = regexp.MustCompile(`// (\[line (\d+)\] )?(Error.*)`)
+)
+
+// ParseExpectations reads the annotations out of source.
+func ParseExpectations(source string) Expectations {
+	var expectations Expectations
+	for index, text := range strings.Split(source, "\n") {
+		line := index + 1
+		if match := expectRuntimeErrorPattern.FindStringSubmatch(text); match != nil {
+			expectations.RuntimeError = &Expectation{Line: line, Text: match[1]}
+		} else if match := expectOutputPattern.FindStringSubmatch(text); match != nil {
+			expectations.Output = append(expectations.Output, Expectation{Line: line, Text: match[1]})
+		} else if match := expectErrorPattern.FindStringSubmatch(text); match != nil {
+			errorLine := line
+			if match[2] != "" {
+				errorLine, _ = strconv.Atoi(match[2])
+			}
+			expectations.Errors = append(expectations.Errors, Expectation{
+				Line: line,
+				Text: fmt.Sprintf("[line %d] %s", errorLine, match[3]),
+			})
+		}
+	}
+	return expectations
+}
+
+// CheckConformance runs source and describes every way what it printed and
+// the diagnostics it raised differ from its annotations. A script that
+// behaves as annotated gets no failures.
+func CheckConformance(source string, run func(source string, stdout io.Writer) (Result, []Diagnostic)) []string {
+	expectations := ParseExpectations(source)
+	var stdout bytes.Buffer
+	_, diagnostics := run(source, &stdout)
+
+	var failures []string
+	var errors []string
+	var runtimeError *Diagnostic
+	for index, diagnostic := range diagnostics {
+		if diagnostic.Code == RUNTIME_ERROR {
+			runtimeError = &diagnostics[index]
+		} else if diagnostic.Severity == SEVERITY_ERROR {
+			errors = append(errors, diagnostic.String())
+		}
+	}
+
+	for index := 0; index < len(errors) || index < len(expectations.Errors); index++ {
+		switch {
+		case index >= len(errors):
+			expected := expectations.Errors[index]
+			failures = append(failures, fmt.Sprintf("Missing expected error '%s' on line %d.", expected.Text, expected.Line))
+		case index >= len(expectations.Errors):
+			failures = append(failures, fmt.Sprintf("Unexpected error '%s'.", errors[index]))
+		case errors[index] != expectations.Errors[index].Text:
+			expected := expectations.Errors[index]
+			failures = append(failures, fmt.Sprintf("Expected error '%s' on line %d and got '%s'.", expected.Text, expected.Line, errors[index]))
+		}
+	}
+
+	expected := expectations.RuntimeError
+	switch {
+	case expected != nil && runtimeError == nil:
+		failures = append(failures, fmt.Sprintf("Missing expected runtime error '%s' on line %d.", expected.Text, expected.Line))
+	case expected != nil && (runtimeError.Message != expected.Text || runtimeError.Line != expected.Line):
+		failures = append(failures, fmt.Sprintf("Expected runtime error '%s' on line %d and got '%s' on line %d.",
+			expected.Text, expected.Line, runtimeError.Message, runtimeError.Line))
+	case expected == nil && runtimeError != nil:
+		failures = append(failures, fmt.Sprintf("Unexpected runtime error '%s' on line %d.", runtimeError.Message, runtimeError.Line))
+	}
+
+	output := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if stdout.Len() == 0 {
+		output = nil
+	}
+	for index := 0; index < len(output) || index < len(expectations.Output); index++ {
+		switch {
+		case index >= len(output):
+			expected := expectations.Output[index]
+			failures = append(failures, fmt.Sprintf("Missing expected output '%s' on line %d.", expected.Text, expected.Line))
+		case index >= len(expectations.Output):
+			failures = append(failures, fmt.Sprintf("Got output '%s' when none was expected.", output[index]))
+		case output[index] != expectations.Output[index].Text:
+			expected := expectations.Output[index]
+			failures = append(failures, fmt.Sprintf("Expected output '%s' on line %d and got '%s'.", expected.Text, expected.Line, output[index]))
+		}
+	}
+	return failures
+}
@@ -0,0 +1,101 @@
+package lox
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestConformance runs this chapter's examples on each backend and checks
+// them against their annotations. Earlier chapters' examples are annotated
+// with what their own interpreters print, such as strings in quotes, so
+// they are not expected to pass here.
+func TestConformance(t *testing.T) {
+	examples, err := filepath.Glob("../examples/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(examples) == 0 {
+		t.Fatal("no examples found")
+	}
+	for _, example := range examples {
+		example := example
+		t.Run(strings.TrimSuffix(filepath.Base(example), ".lox"), func(t *testing.T) {
+			source, err := os.ReadFile(example)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, backend := range backends {
+				failures := CheckConformance(string(source), func(source string, stdout io.Writer) (Result, []Diagnostic) {
+					return backend.run(source, strings.NewReader(""), stdout)
+				})
+				for _, failure := range failures {
+					t.Errorf("%s: %s", backend.name, failure)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckConformance(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "passes",
+			source: "print 1 + 2; // expect: 3\nprint -\"a\"; // expect runtime error: Operand must be a number.\n",
+		},
+		{
+			name:   "compile errors",
+			source: "var 1; // Error at '1': Expect variable name\n// [line 3] Error at end: Expect ';' after value.\nprint 2",
+		},
+		{
+			name:   "wrong output",
+			source: "print 1; // expect: 2\nprint 3;\n",
+			want: []string{
+				"Expected output '2' on line 1 and got '1'.",
+				"Got output '3' when none was expected.",
+			},
+		},
+		{
+			name:   "missing output",
+			source: "print 1; // expect: 1\n// expect: 2\n",
+			want:   []string{"Missing expected output '2' on line 2."},
+		},
+		{
+			name:   "wrong runtime error",
+			source: "\nprint nil + 1; // expect runtime error: Operand must be a number.\n",
+			want: []string{
+				"Expected runtime error 'Operand must be a number.' on line 2 and got 'Operands must be two numbers or two strings.' on line 2.",
+			},
+		},
+		{
+			name:   "unexpected errors",
+			source: "print missing;\nvar 1;\n",
+			want:   []string{"Unexpected error '[line 2] Error at '1': Expect variable name'."},
+		},
+		{
+			name:   "missing runtime error",
+			source: "print 1; // expect: 1\n// expect runtime error: Stack overflow.\n",
+			want:   []string{"Missing expected runtime error 'Stack overflow.' on line 2."},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			for _, backend := range backends {
+				got := CheckConformance(test.source, func(source string, stdout io.Writer) (Result, []Diagnostic) {
+					return backend.run(source, strings.NewReader(""), stdout)
+				})
+				if !reflect.DeepEqual(got, test.want) {
+					t.Errorf("%s: got %q, want %q", backend.name, got, test.want)
+				}
+			}
+		})
+	}
+}
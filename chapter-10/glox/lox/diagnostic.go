@@ -0,0 +1,111 @@
+package lox
+
+import "fmt"
+
+type Severity int
+
+const (
+	SEVERITY_ERROR Severity = iota
+	SEVERITY_WARNING
+)
+
+func (s Severity) String() string {
+	if s == SEVERITY_WARNING {
+		return "warning"
+	}
+	return "error"
+}
+
+// Codes identify which phase raised a diagnostic.
+const (
+	SCAN_ERROR    = "scan-error"
+	PARSE_ERROR   = "parse-error"
+	RESOLVE_ERROR = "resolve-error"
+	COMPILE_ERROR = "compile-error"
+	RUNTIME_ERROR = "runtime-error"
+)
+
+// Span is the half-open byte range [Start, End) of the source a diagnostic
+// points at.
+type Span struct {
+	Start int
+	End   int
+}
+
+// Diagnostic is a single problem raised while scanning, parsing, resolving
+// or running a program. Where holds the book's " at 'lexeme'" fragment used
+// by the text format.
+type Diagnostic struct {
+	Severity Severity
+	Code     string
+	Message  string
+	File     string
+	Line     int
+	Column   int
+	Span     Span
+	Where    string
+}
+
+// String formats the diagnostic the way jlox prints errors.
+func (d Diagnostic) String() string {
+	if d.Code == RUNTIME_ERROR {
+		return fmt.Sprintf("%s\n[line %d]", d.Message, d.Line)
+	}
+	return fmt.Sprintf("[line %d] Error%s: %s", d.Line, d.Where, d.Message)
+}
+
+func newDiagnostic(code string, line int, message string) Diagnostic {
+	return Diagnostic{Severity: SEVERITY_ERROR, Code: code, Line: line, Message: message}
+}
+
+func newTokenDiagnostic(code string, token Token, message string) Diagnostic {
+	diagnostic := newDiagnostic(code, token.Line, message)
+	diagnostic.Column = token.Column
+	diagnostic.Span = Span{Start: token.Offset, End: token.EndOffset}
+	if token.Type == EOF {
+		diagnostic.Where = " at end"
+	} else {
+		diagnostic.Where = " at '" + token.Lexeme + "'"
+	}
+	return diagnostic
+}
+
+func newRuntimeDiagnostic(err RuntimeError) Diagnostic {
+	diagnostic := newDiagnostic(RUNTIME_ERROR, err.Operator.Line, err.Message)
+	diagnostic.Column = err.Operator.Column
+	diagnostic.Span = Span{Start: err.Operator.Offset, End: err.Operator.EndOffset}
+	return diagnostic
+}
+
+// ErrorReporter receives diagnostics as the scanner, parser, resolver and
+// interpreter raise them.
+type ErrorReporter interface {
+	Report(diagnostic Diagnostic)
+}
+
+// DiagnosticCollector is an ErrorReporter that keeps every diagnostic it is
+// given, stamped with the file they belong to.
+type DiagnosticCollector struct {
+	File        string
+	Diagnostics []Diagnostic
+}
+
+func NewDiagnosticCollector(file string) *DiagnosticCollector {
+	return &DiagnosticCollector{File: file}
+}
+
+func (c *DiagnosticCollector) Report(diagnostic Diagnostic) {
+	if diagnostic.File == "" {
+		diagnostic.File = c.File
+	}
+	c.Diagnostics = append(c.Diagnostics, diagnostic)
+}
+
+func (c *DiagnosticCollector) HadError() bool {
+	for _, diagnostic := range c.Diagnostics {
+		if diagnostic.Severity == SEVERITY_ERROR {
+			return true
+		}
+	}
+	return false
+}
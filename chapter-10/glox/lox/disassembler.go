@@ -0,0 +1,118 @@
+package lox
+
+import (
+	"fmt"
+	"io"
+)
+
+// DumpBytecode compiles source and writes the disassembly of every function
+// in it to w, the top-level script first. Nothing is run.
+func DumpBytecode(w io.Writer, file string, source string) (Result, []Diagnostic) {
+	collector := NewDiagnosticCollector(file)
+	statements := analyze(source, nil, collector)
+	if collector.HadError() {
+		return INTERPRET_COMPILE_ERROR, collector.Diagnostics
+	}
+	function := newCompiler(collector).compile(statements)
+	if collector.HadError() {
+		return INTERPRET_COMPILE_ERROR, collector.Diagnostics
+	}
+	disassembleFunction(w, function)
+	return INTERPRET_OK, collector.Diagnostics
+}
+
+// disassembleFunction writes function's chunk followed by the chunks of the
+// functions declared inside it, which live in its constant table.
+func disassembleFunction(w io.Writer, function *vmFunction) {
+	DisassembleChunk(w, &function.chunk, function.String())
+	for _, constant := range function.chunk.Constants {
+		if nested, isFunction := constant.AsCallable().(*vmFunction); isFunction {
+			fmt.Fprintln(w)
+			disassembleFunction(w, nested)
+		}
+	}
+}
+
+// DisassembleChunk writes every instruction in chunk under a header naming
+// it, one per line:
+//
+//	0000    1 OP_CONSTANT         0 '1.5'
+//	0003    | OP_PRINT
+//
+// The second column is the source line, or "|" when it repeats the line
+// above.
+func DisassembleChunk(w io.Writer, chunk *Chunk, name string) {
+	fmt.Fprintf(w, "== %s ==\n", name)
+	for offset := 0; offset < len(chunk.Code); {
+		offset = DisassembleInstruction(w, chunk, offset)
+	}
+}
+
+// DisassembleInstruction writes the instruction at offset and returns the
+// offset of the next one.
+func DisassembleInstruction(w io.Writer, chunk *Chunk, offset int) int {
+	fmt.Fprintf(w, "%04d ", offset)
+	if offset > 0 && chunk.Lines[offset] == chunk.Lines[offset-1] {
+		fmt.Fprint(w, "   | ")
+	} else {
+		fmt.Fprintf(w, "%4d ", chunk.Lines[offset])
+	}
+
+	op := OpCode(chunk.Code[offset])
+	switch op {
+	case OP_CONSTANT, OP_GET_GLOBAL, OP_DEFINE_GLOBAL, OP_SET_GLOBAL,
+		OP_GET_PROPERTY, OP_SET_PROPERTY, OP_CLASS, OP_METHOD:
+		return constantInstruction(w, op, chunk, offset)
+	case OP_GET_LOCAL, OP_SET_LOCAL, OP_GET_UPVALUE, OP_SET_UPVALUE, OP_CALL, OP_INTERPOLATE:
+		return byteInstruction(w, op, chunk, offset)
+	case OP_JUMP, OP_JUMP_IF_FALSE:
+		return jumpInstruction(w, op, 1, chunk, offset)
+	case OP_LOOP:
+		return jumpInstruction(w, op, -1, chunk, offset)
+	case OP_CLOSURE:
+		return closureInstruction(w, chunk, offset)
+	default:
+		fmt.Fprintln(w, op)
+		return offset + 1
+	}
+}
+
+func readShortAt(chunk *Chunk, offset int) int {
+	return int(chunk.Code[offset])<<8 | int(chunk.Code[offset+1])
+}
+
+func constantInstruction(w io.Writer, op OpCode, chunk *Chunk, offset int) int {
+	constant := readShortAt(chunk, offset+1)
+	fmt.Fprintf(w, "%-16s %4d '%s'\n", op, constant, chunk.Constants[constant])
+	return offset + 3
+}
+
+func byteInstruction(w io.Writer, op OpCode, chunk *Chunk, offset int) int {
+	fmt.Fprintf(w, "%-16s %4d\n", op, chunk.Code[offset+1])
+	return offset + 2
+}
+
+func jumpInstruction(w io.Writer, op OpCode, sign int, chunk *Chunk, offset int) int {
+	jump := readShortAt(chunk, offset+1)
+	fmt.Fprintf(w, "%-16s %4d -> %d\n", op, offset, offset+3+sign*jump)
+	return offset + 3
+}
+
+// closureInstruction writes OP_CLOSURE and then one line for each variable
+// the closure captures, saying where it is captured from.
+func closureInstruction(w io.Writer, chunk *Chunk, offset int) int {
+	constant := readShortAt(chunk, offset+1)
+	value := chunk.Constants[constant]
+	fmt.Fprintf(w, "%-16s %4d %s\n", OP_CLOSURE, constant, value)
+	offset += 3
+	function := value.AsCallable().(*vmFunction)
+	for i := 0; i < function.upvalueCount; i++ {
+		kind := "upvalue"
+		if chunk.Code[offset] == 1 {
+			kind = "local"
+		}
+		fmt.Fprintf(w, "%04d    |                     %s %d\n", offset, kind, chunk.Code[offset+1])
+		offset += 2
+	}
+	return offset
+}
@@ -0,0 +1,71 @@
+package lox
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDumpBytecode(t *testing.T) {
+	source := `var x = 1;
+fun add(a) {
+  return a + x;
+}
+print add(2);
+`
+	want := `== <script> ==
+0000    1 OP_CONSTANT         0 '1'
+0003    | OP_DEFINE_GLOBAL    1 'x'
+0006    2 OP_CLOSURE          2 <fn add>
+0009    | OP_DEFINE_GLOBAL    3 'add'
+0012    5 OP_GET_GLOBAL       4 'add'
+0015    | OP_CONSTANT         5 '2'
+0018    | OP_CALL             1
+0020    | OP_PRINT
+0021    | OP_NIL
+0022    | OP_RETURN
+
+== <fn add> ==
+0000    3 OP_GET_LOCAL        1
+0002    | OP_GET_GLOBAL       0 'x'
+0005    | OP_ADD
+0006    | OP_RETURN
+0007    | OP_NIL
+0008    | OP_RETURN
+`
+	var output strings.Builder
+	result, diagnostics := DumpBytecode(&output, "", source)
+	if result != INTERPRET_OK {
+		t.Fatal(diagnostics)
+	}
+	if output.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", output.String(), want)
+	}
+}
+
+// TestDumpBytecodeLiteralLines checks literals are put on their own lines
+// rather than that of the token compiled before them.
+func TestDumpBytecodeLiteralLines(t *testing.T) {
+	source := `var a = "one" +
+  "two";
+print
+  nil;
+`
+	want := `== <script> ==
+0000    1 OP_CONSTANT         0 'one'
+0003    2 OP_CONSTANT         1 'two'
+0006    1 OP_ADD
+0007    | OP_DEFINE_GLOBAL    2 'a'
+0010    4 OP_NIL
+0011    | OP_PRINT
+0012    | OP_NIL
+0013    | OP_RETURN
+`
+	var output strings.Builder
+	result, diagnostics := DumpBytecode(&output, "", source)
+	if result != INTERPRET_OK {
+		t.Fatal(diagnostics)
+	}
+	if output.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", output.String(), want)
+	}
+}
@@ -1,48 +1,76 @@
-package main
+package lox
 
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Environment holds the variables of a single scope. The global scope keeps
+// its variables in a map since globals are late bound by name. Local scopes
+// store their variables in slots, in declaration order, so the resolver can
+// hand the interpreter a (depth, slot) pair for every local access.
 type Environment struct {
-	values    map[string]interface{}
+	values    map[string]Value
+	names     []string
+	slots     []Value
 	enclosing *Environment
 }
 
 func NewEnvironment(env *Environment) *Environment {
-	return &Environment{
-		values:    make(map[string]interface{}),
+	environment := &Environment{
 		enclosing: env,
 	}
+	if env == nil {
+		environment.values = make(map[string]Value)
+	}
+	return environment
 }
 
 func (env *Environment) Copy() *Environment {
 	// Create a new environment with the same enclosing environment
 	newEnv := &Environment{
-		values:    make(map[string]interface{}),
 		enclosing: env.enclosing,
+		names:     append([]string(nil), env.names...),
+		slots:     append([]Value(nil), env.slots...),
 	}
 
 	// Copy the values from the current environment to the new one
-	for key, value := range env.values {
-		newEnv.values[key] = value
+	if env.values != nil {
+		newEnv.values = make(map[string]Value, len(env.values))
+		for key, value := range env.values {
+			newEnv.values[key] = value
+		}
 	}
 
 	return newEnv
 }
-func (e *Environment) Define(name string, value interface{}) {
-	e.values[name] = value
+
+// Define binds name in this scope. Globals are stored by name, locals are
+// appended to the next free slot.
+func (e *Environment) Define(name string, value Value) {
+	if e.values != nil {
+		e.values[name] = value
+		return
+	}
+	e.names = append(e.names, name)
+	e.slots = append(e.slots, value)
 }
 
-func (e Environment) Get(name Token) (interface{}, error) {
+func (e *Environment) Get(name Token) (Value, error) {
 	value, exists := e.values[name.Lexeme]
 	if !exists {
 		if e.enclosing != nil {
 			return e.enclosing.Get(name)
 		}
-		return nil, RuntimeError{Operator: name, Message: "Undefined variable '" + name.Lexeme + "'."}
+		return Value{}, RuntimeError{Operator: name, Message: "Undefined variable '" + name.Lexeme + "'."}
 	} else {
 		return value, nil
 	}
 }
 
-func (e *Environment) Assign(name Token, value interface{}) error {
+func (e *Environment) Assign(name Token, value Value) error {
 	_, exists := e.values[name.Lexeme]
 	if !exists {
 		if e.enclosing != nil {
@@ -54,3 +82,74 @@ func (e *Environment) Assign(name Token, value interface{}) error {
 		return nil
 	}
 }
+
+// Names returns the names defined in this scope, sorted.
+func (e *Environment) Names() []string {
+	if e.values != nil {
+		return sortedNames(e.values)
+	}
+	names := append([]string(nil), e.names...)
+	sort.Strings(names)
+	return names
+}
+
+// GetAt reads the local in the given slot of the scope depth hops up.
+func (e *Environment) GetAt(depth int, slot int) Value {
+	return e.ancestor(depth).slots[slot]
+}
+
+// AssignAt writes the local in the given slot of the scope depth hops up.
+func (e *Environment) AssignAt(depth int, slot int, value Value) {
+	e.ancestor(depth).slots[slot] = value
+}
+
+func (e *Environment) ancestor(depth int) *Environment {
+	environment := e
+	for i := 0; i < depth; i++ {
+		environment = environment.enclosing
+	}
+	return environment
+}
+
+// Dump writes every variable in this scope and the ones enclosing it, from
+// the innermost out, for inspecting scoping by hand.
+func (e *Environment) Dump(w io.Writer) {
+	depth := 0
+	for environment := e; environment != nil; environment = environment.enclosing {
+		if environment.values != nil {
+			dumpGlobals(w, environment.values)
+			continue
+		}
+		fmt.Fprintf(w, "scope %d:\n", depth)
+		for slot, name := range environment.names {
+			fmt.Fprintf(w, "  %s = %s\n", name, inspect(environment.slots[slot]))
+		}
+		depth++
+	}
+}
+
+// dumpGlobals writes globals sorted by name.
+func dumpGlobals(w io.Writer, globals map[string]Value) {
+	fmt.Fprintln(w, "globals:")
+	for _, name := range sortedNames(globals) {
+		fmt.Fprintf(w, "  %s = %s\n", name, inspect(globals[name]))
+	}
+}
+
+func sortedNames(values map[string]Value) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inspect formats value the way it would be written in source where it
+// can be, so a string stands apart from a number or a keyword.
+func inspect(value Value) string {
+	if value.IsString() {
+		return strconv.Quote(value.AsString())
+	}
+	return value.String()
+}
@@ -0,0 +1,32 @@
+package lox
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEnvironmentDump(t *testing.T) {
+	globals := NewEnvironment(nil)
+	globals.Define("b", NumberValue(2))
+	globals.Define("a", StringValue("one"))
+	outer := NewEnvironment(globals)
+	outer.Define("x", BoolValue(true))
+	inner := NewEnvironment(outer)
+	inner.Define("y", NilValue())
+	inner.Define("z", StringValue("2"))
+
+	var output bytes.Buffer
+	inner.Dump(&output)
+	want := `scope 0:
+  y = nil
+  z = "2"
+scope 1:
+  x = true
+globals:
+  a = "one"
+  b = 2
+`
+	if output.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", output.String(), want)
+	}
+}
@@ -1,16 +1,21 @@
-package main
+package lox
 
 type Expr interface {
 	Accept(v ExprVisitor) (interface{}, error)
 }
 type ExprVisitor interface {
-	VisitAssignExpr(expr Assign) (interface{}, error)
-	VisitBinaryExpr(expr Binary) (interface{}, error)
-	VisitGroupingExpr(expr Grouping) (interface{}, error)
-	VisitLiteralExpr(expr Literal) (interface{}, error)
-	VisitLogicalExpr(expr Logical) (interface{}, error)
-	VisitUnaryExpr(expr Unary) (interface{}, error)
-	VisitVariableExpr(expr Variable) (interface{}, error)
+	VisitAssignExpr(expr *Assign) (interface{}, error)
+	VisitBinaryExpr(expr *Binary) (interface{}, error)
+	VisitCallExpr(expr *Call) (interface{}, error)
+	VisitGetExpr(expr *Get) (interface{}, error)
+	VisitGroupingExpr(expr *Grouping) (interface{}, error)
+	VisitInterpolationExpr(expr *Interpolation) (interface{}, error)
+	VisitLiteralExpr(expr *Literal) (interface{}, error)
+	VisitLogicalExpr(expr *Logical) (interface{}, error)
+	VisitSetExpr(expr *Set) (interface{}, error)
+	VisitThisExpr(expr *This) (interface{}, error)
+	VisitUnaryExpr(expr *Unary) (interface{}, error)
+	VisitVariableExpr(expr *Variable) (interface{}, error)
 }
 
 type Assign struct {
@@ -18,13 +23,13 @@ type Assign struct {
 	value Expr
 }
 
-func NewAssign(name Token, value Expr) Assign {
-	return Assign{
+func NewAssign(name Token, value Expr) *Assign {
+	return &Assign{
 		name,
 		value,
 	}
 }
-func (a Assign) Accept(visitor ExprVisitor) (interface{}, error) {
+func (a *Assign) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitAssignExpr(a)
 }
 
@@ -34,40 +39,89 @@ type Binary struct {
 	right    Expr
 }
 
-func NewBinary(left Expr, operator Token, right Expr) Binary {
-	return Binary{
+func NewBinary(left Expr, operator Token, right Expr) *Binary {
+	return &Binary{
 		left,
 		operator,
 		right,
 	}
 }
-func (a Binary) Accept(visitor ExprVisitor) (interface{}, error) {
+func (a *Binary) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitBinaryExpr(a)
 }
 
+type Call struct {
+	callee    Expr
+	paren     Token
+	arguments []Expr
+}
+
+func NewCall(callee Expr, paren Token, arguments []Expr) *Call {
+	return &Call{
+		callee,
+		paren,
+		arguments,
+	}
+}
+func (a *Call) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitCallExpr(a)
+}
+
+type Get struct {
+	object Expr
+	name   Token
+}
+
+func NewGet(object Expr, name Token) *Get {
+	return &Get{
+		object,
+		name,
+	}
+}
+func (a *Get) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitGetExpr(a)
+}
+
 type Grouping struct {
 	expression Expr
 }
 
-func NewGrouping(expression Expr) Grouping {
-	return Grouping{
+func NewGrouping(expression Expr) *Grouping {
+	return &Grouping{
 		expression,
 	}
 }
-func (a Grouping) Accept(visitor ExprVisitor) (interface{}, error) {
+func (a *Grouping) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitGroupingExpr(a)
 }
 
+type Interpolation struct {
+	start Token
+	parts []Expr
+}
+
+func NewInterpolation(start Token, parts []Expr) *Interpolation {
+	return &Interpolation{
+		start,
+		parts,
+	}
+}
+func (a *Interpolation) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitInterpolationExpr(a)
+}
+
 type Literal struct {
-	value interface{}
+	token Token
+	value Value
 }
 
-func NewLiteral(value interface{}) Literal {
-	return Literal{
+func NewLiteral(token Token, value Value) *Literal {
+	return &Literal{
+		token,
 		value,
 	}
 }
-func (a Literal) Accept(visitor ExprVisitor) (interface{}, error) {
+func (a *Literal) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitLiteralExpr(a)
 }
 
@@ -77,29 +131,59 @@ type Logical struct {
 	right    Expr
 }
 
-func NewLogical(left Expr, operator Token, right Expr) Logical {
-	return Logical{
+func NewLogical(left Expr, operator Token, right Expr) *Logical {
+	return &Logical{
 		left,
 		operator,
 		right,
 	}
 }
-func (a Logical) Accept(visitor ExprVisitor) (interface{}, error) {
+func (a *Logical) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitLogicalExpr(a)
 }
 
+type Set struct {
+	object Expr
+	name   Token
+	value  Expr
+}
+
+func NewSet(object Expr, name Token, value Expr) *Set {
+	return &Set{
+		object,
+		name,
+		value,
+	}
+}
+func (a *Set) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitSetExpr(a)
+}
+
+type This struct {
+	keyword Token
+}
+
+func NewThis(keyword Token) *This {
+	return &This{
+		keyword,
+	}
+}
+func (a *This) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitThisExpr(a)
+}
+
 type Unary struct {
 	operator Token
 	right    Expr
 }
 
-func NewUnary(operator Token, right Expr) Unary {
-	return Unary{
+func NewUnary(operator Token, right Expr) *Unary {
+	return &Unary{
 		operator,
 		right,
 	}
 }
-func (a Unary) Accept(visitor ExprVisitor) (interface{}, error) {
+func (a *Unary) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitUnaryExpr(a)
 }
 
@@ -107,11 +191,11 @@ type Variable struct {
 	name Token
 }
 
-func NewVariable(name Token) Variable {
-	return Variable{
+func NewVariable(name Token) *Variable {
+	return &Variable{
 		name,
 	}
 }
-func (a Variable) Accept(visitor ExprVisitor) (interface{}, error) {
+func (a *Variable) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitVariableExpr(a)
 }
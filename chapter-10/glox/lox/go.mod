@@ -1,4 +1,4 @@
-module glox
+module glox/lox
 
 go 1.20
 
@@ -0,0 +1,159 @@
+package lox
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/*.golden from the current output")
+
+// backends runs source on a fresh instance of each way of executing Lox,
+// reading input from stdin and writing print output to stdout.
+var backends = []struct {
+	name string
+	run  func(source string, stdin io.Reader, stdout io.Writer) (Result, []Diagnostic)
+}{
+	{"interpreter", func(source string, stdin io.Reader, stdout io.Writer) (Result, []Diagnostic) {
+		interpreter := NewInterpreter()
+		interpreter.SetInput(stdin)
+		interpreter.SetOutput(stdout)
+		return interpreter.Run(source)
+	}},
+	{"vm", func(source string, stdin io.Reader, stdout io.Writer) (Result, []Diagnostic) {
+		vm := NewVM()
+		vm.SetInput(stdin)
+		vm.SetOutput(stdout)
+		return vm.Run(source)
+	}},
+	{"vm-gc-stress", func(source string, stdin io.Reader, stdout io.Writer) (Result, []Diagnostic) {
+		vm := NewVM()
+		vm.StressGC = true
+		vm.SetInput(stdin)
+		vm.SetOutput(stdout)
+		return vm.Run(source)
+	}},
+}
+
+// TestGolden runs every testdata/*.lox script on each backend and compares
+// what it prints, followed by any runtime error, with the matching .golden
+// file.
+func TestGolden(t *testing.T) {
+	scripts, err := filepath.Glob("testdata/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, script := range scripts {
+		script := script
+		t.Run(strings.TrimSuffix(filepath.Base(script), ".lox"), func(t *testing.T) {
+			source, err := os.ReadFile(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := strings.TrimSuffix(script, ".lox") + ".golden"
+			if *update {
+				got := runCapturingOutput(backends[0].run, string(source), "")
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, backend := range backends {
+				got := runCapturingOutput(backend.run, string(source), "")
+				if got != string(want) {
+					t.Errorf("%s output mismatch\n--- got ---\n%s--- want ---\n%s", backend.name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestRuntimeErrorLocations checks every backend points a runtime error
+// at the same column and span of the source as the tree-walker does, so
+// the rendered snippet underlines the same code.
+func TestRuntimeErrorLocations(t *testing.T) {
+	scripts, err := filepath.Glob("testdata/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, script := range scripts {
+		source, err := os.ReadFile(script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, want := backends[0].run(string(source), strings.NewReader(""), io.Discard)
+		for _, backend := range backends[1:] {
+			_, got := backend.run(string(source), strings.NewReader(""), io.Discard)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: %s diagnostics %+v, want %+v", script, backend.name, got, want)
+			}
+		}
+		for _, diagnostic := range want {
+			if diagnostic.Code == RUNTIME_ERROR && diagnostic.Column == 0 {
+				t.Errorf("%s: runtime error %q has no column", script, diagnostic.Message)
+			}
+		}
+	}
+}
+
+// TestVMMatchesInterpreter checks the VM prints exactly what the
+// tree-walker does for every example program.
+func TestVMMatchesInterpreter(t *testing.T) {
+	examples, err := filepath.Glob("../examples/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, example := range examples {
+		example := example
+		t.Run(strings.TrimSuffix(filepath.Base(example), ".lox"), func(t *testing.T) {
+			source, err := os.ReadFile(example)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := runCapturingOutput(backends[0].run, string(source), "")
+			got := runCapturingOutput(backends[1].run, string(source), "")
+			if got != want {
+				t.Errorf("output mismatch\n--- vm ---\n%s--- interpreter ---\n%s", got, want)
+			}
+		})
+	}
+}
+
+// TestReadLine checks readLine() returns each line of input without its
+// line ending, then nil once the input runs out.
+func TestReadLine(t *testing.T) {
+	source := `
+var line = readLine();
+while (line != nil) {
+  print "<" + line + ">";
+  line = readLine();
+}
+`
+	want := "<first>\n<>\n<last>\n"
+	for _, backend := range backends {
+		got := runCapturingOutput(backend.run, source, "first\r\n\nlast")
+		if got != want {
+			t.Errorf("%s output mismatch\n--- got ---\n%s--- want ---\n%s", backend.name, got, want)
+		}
+	}
+}
+
+// runCapturingOutput runs source with input as stdin and returns everything
+// print wrote, followed by the text form of each diagnostic raised.
+func runCapturingOutput(run func(source string, stdin io.Reader, stdout io.Writer) (Result, []Diagnostic), source string, input string) string {
+	var output bytes.Buffer
+	_, diagnostics := run(source, strings.NewReader(input), &output)
+	for _, diagnostic := range diagnostics {
+		output.WriteString(diagnostic.String() + "\n")
+	}
+	return output.String()
+}
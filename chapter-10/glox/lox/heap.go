@@ -0,0 +1,241 @@
+package lox
+
+import (
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+const (
+	initialGCThreshold = 1024 * 1024
+	gcHeapGrowFactor   = 2
+)
+
+// objectHeader is embedded in every object the VM allocates. It links the
+// object into the heap's list of all objects and remembers how many bytes
+// were accounted for it, so sweeping gives back exactly that many.
+type objectHeader struct {
+	marked bool
+	size   int
+	next   heapObject
+}
+
+func (h *objectHeader) header() *objectHeader {
+	return h
+}
+
+// heapObject is an object living on the VM heap.
+type heapObject interface {
+	header() *objectHeader
+	// byteSize estimates the memory the object holds when allocated.
+	byteSize() int
+	// trace marks every heap object the object refers to.
+	trace(h *heap)
+}
+
+// vmString accounts for a string the VM built at runtime. Values carry the
+// string itself; the record only tracks whether anything still refers to it.
+type vmString struct {
+	objectHeader
+	chars string
+}
+
+func (s *vmString) String() string {
+	return s.chars
+}
+
+// GCStats counts what the garbage collector has done since the VM started.
+type GCStats struct {
+	Collections    int
+	BytesAllocated int
+	BytesFreed     int
+	ObjectsFreed   int
+}
+
+// heap is the mark-sweep collected heap of a VM. Collection is driven by
+// the number of bytes allocated since the last one, not by Go's collector:
+// sweeping an object drops the heap's references to it and leaves freeing
+// the memory to Go.
+type heap struct {
+	objects   heapObject
+	live      int
+	nextGC    int
+	grayStack []heapObject
+	stats     GCStats
+}
+
+func newHeap() *heap {
+	return &heap{nextGC: initialGCThreshold}
+}
+
+func (h *heap) link(object heapObject) {
+	header := object.header()
+	header.size = object.byteSize()
+	header.next = h.objects
+	h.objects = object
+	h.live += header.size
+	h.stats.BytesAllocated += header.size
+}
+
+// grow accounts for an object that took on more memory after it was
+// allocated, such as an instance gaining a field.
+func (h *heap) grow(object heapObject, bytes int) {
+	object.header().size += bytes
+	h.live += bytes
+	h.stats.BytesAllocated += bytes
+}
+
+func (h *heap) markValue(value Value) {
+	switch value.Type {
+	case VAL_STRING, VAL_OBJECT:
+		if object, isHeapObject := value.object.(heapObject); isHeapObject {
+			h.markObject(object)
+		}
+	case VAL_CALLABLE:
+		// Natives are owned by the host, not the heap.
+		if object, isHeapObject := value.callable.(heapObject); isHeapObject {
+			h.markObject(object)
+		}
+	}
+}
+
+func (h *heap) markObject(object heapObject) {
+	header := object.header()
+	if header.marked {
+		return
+	}
+	header.marked = true
+	h.grayStack = append(h.grayStack, object)
+}
+
+// traceReferences blackens gray objects until none are left, at which
+// point every reachable object is marked.
+func (h *heap) traceReferences() {
+	for len(h.grayStack) > 0 {
+		object := h.grayStack[len(h.grayStack)-1]
+		h.grayStack = h.grayStack[:len(h.grayStack)-1]
+		object.trace(h)
+	}
+}
+
+// sweep unlinks every unmarked object and clears the mark on the rest.
+func (h *heap) sweep() {
+	var previous heapObject
+	object := h.objects
+	for object != nil {
+		header := object.header()
+		if header.marked {
+			header.marked = false
+			previous = object
+			object = header.next
+			continue
+		}
+		object = header.next
+		if previous == nil {
+			h.objects = object
+		} else {
+			previous.header().next = object
+		}
+		header.next = nil
+		h.live -= header.size
+		h.stats.BytesFreed += header.size
+		h.stats.ObjectsFreed++
+	}
+}
+
+// collect finishes a collection whose roots the VM has already marked.
+func (h *heap) collect(log io.Writer) {
+	before := h.live
+	freedObjects := h.stats.ObjectsFreed
+	h.traceReferences()
+	h.sweep()
+	h.nextGC = h.live * gcHeapGrowFactor
+	if h.nextGC < initialGCThreshold {
+		h.nextGC = initialGCThreshold
+	}
+	h.stats.Collections++
+	if log != nil {
+		fmt.Fprintf(log, "gc: collected %d bytes in %d objects (from %d to %d), next at %d\n",
+			before-h.live, h.stats.ObjectsFreed-freedObjects, before, h.live, h.nextGC)
+	}
+}
+
+func (s *vmString) byteSize() int {
+	return int(unsafe.Sizeof(*s)) + len(s.chars)
+}
+
+func (s *vmString) trace(h *heap) {}
+
+func (f *vmFunction) byteSize() int {
+	chunk := f.chunk
+	return int(unsafe.Sizeof(*f)) + len(chunk.Code) + len(chunk.Lines)*int(unsafe.Sizeof(0)) +
+		len(chunk.Columns)*int(unsafe.Sizeof(0)) + len(chunk.Spans)*int(unsafe.Sizeof(Span{})) +
+		len(chunk.Constants)*int(unsafe.Sizeof(Value{}))
+}
+
+func (f *vmFunction) trace(h *heap) {
+	for _, constant := range f.chunk.Constants {
+		h.markValue(constant)
+	}
+}
+
+func (c *vmClosure) byteSize() int {
+	return int(unsafe.Sizeof(*c)) + len(c.upvalues)*int(unsafe.Sizeof(c))
+}
+
+func (c *vmClosure) trace(h *heap) {
+	h.markObject(c.function)
+	for _, upvalue := range c.upvalues {
+		// Upvalues are filled in after the closure is allocated.
+		if upvalue != nil {
+			h.markObject(upvalue)
+		}
+	}
+}
+
+func (u *vmUpvalue) byteSize() int {
+	return int(unsafe.Sizeof(*u))
+}
+
+// trace only follows closed upvalues; an open one's value is on the stack.
+func (u *vmUpvalue) trace(h *heap) {
+	if !u.open {
+		h.markValue(u.closed)
+	}
+}
+
+func (c *vmClass) byteSize() int {
+	return int(unsafe.Sizeof(*c)) + len(c.name)
+}
+
+func (c *vmClass) trace(h *heap) {
+	for _, method := range c.methods {
+		h.markObject(method)
+	}
+}
+
+func (i *vmInstance) byteSize() int {
+	return int(unsafe.Sizeof(*i))
+}
+
+func (i *vmInstance) trace(h *heap) {
+	h.markObject(i.class)
+	for _, value := range i.fields {
+		h.markValue(value)
+	}
+}
+
+func (b *vmBoundMethod) byteSize() int {
+	return int(unsafe.Sizeof(*b))
+}
+
+func (b *vmBoundMethod) trace(h *heap) {
+	h.markValue(b.receiver)
+	h.markObject(b.method)
+}
+
+// entrySize is what the heap accounts for one more entry in a map of
+// values, such as a new field or method.
+func entrySize(name string) int {
+	return len(name) + int(unsafe.Sizeof(Value{}))
+}
@@ -0,0 +1,53 @@
+package lox
+
+import (
+	"strings"
+	"testing"
+)
+
+const garbage = `
+class Box {
+  init(value) {
+    this.value = value;
+  }
+}
+fun churn() {
+  var text = "";
+  for (var i = 0; i < 100; i = i + 1) {
+    var box = Box(i);
+    text = text + "x";
+  }
+  return text;
+}
+var kept = churn();
+`
+
+func TestStressGCFreesUnreachableObjects(t *testing.T) {
+	var log strings.Builder
+	vm := NewVM()
+	vm.StressGC = true
+	vm.GCLog = &log
+	result, diagnostics := vm.Run(garbage)
+	if result != INTERPRET_OK {
+		t.Fatal(diagnostics)
+	}
+
+	stats := vm.GCStats()
+	if stats.Collections == 0 || stats.ObjectsFreed == 0 {
+		t.Fatalf("expected collections to free objects, got %+v", stats)
+	}
+	if live := stats.BytesAllocated - stats.BytesFreed; live != vm.heap.live {
+		t.Errorf("stats account for %d live bytes, heap holds %d", live, vm.heap.live)
+	}
+	if lines := strings.Count(log.String(), "\n"); lines != stats.Collections {
+		t.Errorf("logged %d collections, ran %d", lines, stats.Collections)
+	}
+
+	// Everything churn allocated is garbage except the string it returned.
+	vm.collectGarbage()
+	for object := vm.heap.objects; object != nil; object = object.header().next {
+		if _, isInstance := object.(*vmInstance); isInstance {
+			t.Errorf("instance %v survived a collection with nothing referring to it", object)
+		}
+	}
+}
@@ -1,178 +1,314 @@
-package main
+package lox
 
 import (
+	"context"
 	"fmt"
-	"strconv"
+	"io"
+	"strings"
 )
 
 type Interpreter struct {
+	streams
+	globals     *Environment
 	environment *Environment
+	locals      map[Expr]binding
+	limits      Limits
+	usage       usage
+	ctx         context.Context
+	// scopes holds the environments that the blocks and calls being run
+	// have set aside, innermost last, so measureHeap can trace them.
+	scopes []*Environment
 }
 
+// binding locates a resolved local: how many scopes up it lives and which
+// slot it occupies there.
+type binding struct {
+	depth int
+	slot  int
+}
+
+// RuntimeErrorKind tells a script that failed apart from one that was
+// stopped for exceeding a limit or being cancelled.
+type RuntimeErrorKind int
+
+const (
+	RUNTIME_FAILURE RuntimeErrorKind = iota
+	RUNTIME_STEP_LIMIT
+	RUNTIME_STACK_OVERFLOW
+	RUNTIME_HEAP_LIMIT
+	RUNTIME_CANCELLED
+)
+
 type RuntimeError struct {
 	Operator Token
 	Message  string
+	Kind     RuntimeErrorKind
 }
 
 func (e RuntimeError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Operator, e.Message)
 }
 
-func NewInterpreter() Interpreter {
-	return Interpreter{
-		environment: NewEnvironment(nil),
+func NewInterpreter() *Interpreter {
+	globals := NewEnvironment(nil)
+	interpreter := &Interpreter{
+		globals:     globals,
+		environment: globals,
+		locals:      make(map[Expr]binding),
+		ctx:         context.Background(),
+		streams:     newStreams(),
 	}
+	defineNatives(interpreter)
+	return interpreter
 }
 
-func (i *Interpreter) Interpret(stmts []Stmt) {
+// DumpEnvironment writes the variables in scope, innermost first.
+func (i *Interpreter) DumpEnvironment(w io.Writer) {
+	i.environment.Dump(w)
+}
+
+// GlobalNames returns the names of the global variables, sorted.
+func (i *Interpreter) GlobalNames() []string {
+	return i.globals.Names()
+}
+
+// DefineNative registers a Go function as a global Lox function. Embedders
+// use it to expose host functionality without touching the interpreter.
+func (i *Interpreter) DefineNative(name string, arity int, fn func(host Host, arguments []Value) (Value, error)) {
+	i.globals.Define(name, CallableValue(NewNativeFunction(name, arity, fn)))
+}
+
+// Interpret executes stmts in order and stops at the first runtime error,
+// which it returns.
+func (i *Interpreter) Interpret(stmts []Stmt) error {
+	return i.InterpretContext(context.Background(), stmts)
+}
+
+// InterpretContext is Interpret that also stops, with a RUNTIME_CANCELLED
+// error, once ctx is done. Limits apply to each call separately.
+func (i *Interpreter) InterpretContext(ctx context.Context, stmts []Stmt) error {
+	i.ctx = ctx
+	i.usage = usage{}
+	defer func() {
+		i.ctx = context.Background()
+	}()
 	for _, stmt := range stmts {
 		_, err := i.execute(stmt)
 		if err != nil {
-			ReportRuntimeError(err.(RuntimeError))
+			return err
 		}
 	}
-
+	return nil
 }
-func (i *Interpreter) VisitBinaryExpr(expr Binary) (interface{}, error) {
-	var err error
-	var right interface{}
-	var left interface{}
-	right, err = i.evaluate(expr.right)
+func (i *Interpreter) VisitBinaryExpr(expr *Binary) (interface{}, error) {
+	left, err := i.evaluate(expr.left)
 	if err != nil {
 		return nil, err
 	}
-	left, err = i.evaluate(expr.left)
+	right, err := i.evaluate(expr.right)
 	if err != nil {
 		return nil, err
 	}
 	switch expr.operator.Type {
-	case GREATER:
-		err = checkNumberOperands(expr.operator, left, right)
-		if err != nil {
-			return nil, err
+	case BANG_EQUAL:
+		return BoolValue(!left.Equal(right)), nil
+	case EQUAL_EQUAL:
+		return BoolValue(left.Equal(right)), nil
+	case PLUS:
+		if left.IsNumber() && right.IsNumber() {
+			return NumberValue(left.AsNumber() + right.AsNumber()), nil
 		}
-		return left.(float64) >
-			right.(float64), nil
-	case GREATER_EQUAL:
-		err = checkNumberOperands(expr.operator, left, right)
-		if err != nil {
-			return nil, err
+		if left.IsString() && right.IsString() {
+			value := left.AsString() + right.AsString()
+			err = i.allocate(expr.operator, len(value))
+			if err != nil {
+				return nil, err
+			}
+			return StringValue(value), nil
 		}
-		return left.(float64) >= right.(float64), nil
+		return nil, RuntimeError{Operator: expr.operator, Message: "Operands must be two numbers or two strings."}
+	}
+	err = checkNumberOperands(expr.operator, left, right)
+	if err != nil {
+		return nil, err
+	}
+	a := left.AsNumber()
+	b := right.AsNumber()
+	switch expr.operator.Type {
+	case GREATER:
+		return BoolValue(a > b), nil
+	case GREATER_EQUAL:
+		return BoolValue(a >= b), nil
 	case LESS:
-		err = checkNumberOperands(expr.operator, left, right)
-		if err != nil {
-			return nil, err
-		}
-		return left.(float64) < right.(float64), nil
+		return BoolValue(a < b), nil
 	case LESS_EQUAL:
-		err = checkNumberOperands(expr.operator, left, right)
-		if err != nil {
-			return nil, err
-		}
-		return left.(float64) <= right.(float64), nil
+		return BoolValue(a <= b), nil
 	case MINUS:
-		err = checkNumberOperands(expr.operator, left, right)
+		return NumberValue(a - b), nil
+	case SLASH:
+		return NumberValue(a / b), nil
+	case STAR:
+		return NumberValue(a * b), nil
+	}
+	// unreachable
+	return nil, nil
+}
+func (i *Interpreter) VisitCallExpr(expr *Call) (interface{}, error) {
+	callee, err := i.evaluate(expr.callee)
+	if err != nil {
+		return nil, err
+	}
+	var arguments []Value
+	for _, argument := range expr.arguments {
+		value, err := i.evaluate(argument)
 		if err != nil {
 			return nil, err
 		}
-		value := left.(float64) - right.(float64)
-		return strconv.FormatFloat(value, 'f', -1, 64), nil
-	case BANG_EQUAL:
-		return !isEqual(left, right), nil
-	case EQUAL_EQUAL:
-		return isEqual(left, right), nil
-	case PLUS:
-		r := isNumber(right)
-		l := isNumber(left)
-		if l && r {
-			value := left.(float64) + right.(float64)
-			return value, nil
-		} else {
-			var leftvalue string
-			var rightvalue string
-			if l {
-				leftvalue = strconv.FormatFloat(left.(float64), 'f', -1, 64)
-			} else {
-				leftvalue = left.(string)
-			}
-			if r {
-				rightvalue = strconv.FormatFloat(right.(float64), 'f', -1, 64)
-			} else {
-				rightvalue = right.(string)
+		arguments = append(arguments, value)
+	}
+	function, isCallable := callee.AsCallable().(LoxCallable)
+	if !isCallable {
+		return nil, RuntimeError{Operator: expr.paren, Message: "Can only call functions and classes."}
+	}
+	if len(arguments) != function.Arity() {
+		return nil, RuntimeError{Operator: expr.paren, Message: fmt.Sprintf("Expected %d arguments but got %d.", function.Arity(), len(arguments))}
+	}
+	err = i.enterCall(expr.paren, function)
+	if err != nil {
+		return nil, err
+	}
+	result, err := function.Call(i, arguments)
+	i.usage.depth--
+	if _, isNative := function.(*NativeFunction); isNative && err != nil {
+		err = nativeError(err, func(message string) RuntimeError {
+			return RuntimeError{Operator: expr.paren, Message: message}
+		})
+	}
+	return result, err
+}
+func (i *Interpreter) VisitGetExpr(expr *Get) (interface{}, error) {
+	object, err := i.evaluate(expr.object)
+	if err != nil {
+		return nil, err
+	}
+	if instance, isInstance := object.AsObject().(*LoxInstance); isInstance {
+		if _, isField := instance.fields[expr.name.Lexeme]; !isField {
+			// Reading a method binds it to a new environment holding "this".
+			err = i.allocate(expr.name, boundMethodBytes)
+			if err != nil {
+				return nil, err
 			}
-			value := leftvalue + rightvalue
-			return value, nil
 		}
-	case SLASH:
-		err = checkNumberOperands(expr.operator, left, right)
+		return instance.Get(expr.name)
+	}
+	return nil, RuntimeError{Operator: expr.name, Message: "Only instances have properties."}
+}
+func (i *Interpreter) VisitSetExpr(expr *Set) (interface{}, error) {
+	object, err := i.evaluate(expr.object)
+	if err != nil {
+		return nil, err
+	}
+	instance, isInstance := object.AsObject().(*LoxInstance)
+	if !isInstance {
+		return nil, RuntimeError{Operator: expr.name, Message: "Only instances have fields."}
+	}
+	value, err := i.evaluate(expr.value)
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := instance.fields[expr.name.Lexeme]; !exists {
+		err = i.allocate(expr.name, entrySize(expr.name.Lexeme))
 		if err != nil {
 			return nil, err
 		}
-		value := left.(float64) / right.(float64)
-		return strconv.FormatFloat(value, 'f', -1, 64), nil
-	case STAR:
-		err = checkNumberOperands(expr.operator, left, right)
+	}
+	instance.Set(expr.name, value)
+	return value, nil
+}
+func (i *Interpreter) VisitThisExpr(expr *This) (interface{}, error) {
+	return i.lookUpVariable(expr.keyword, expr)
+}
+func (i *Interpreter) VisitGroupingExpr(expr *Grouping) (interface{}, error) {
+	return i.evaluate(expr.expression)
+}
+
+// VisitInterpolationExpr joins the parts of an interpolated string, each
+// converted to a string the way print would show it.
+func (i *Interpreter) VisitInterpolationExpr(expr *Interpolation) (interface{}, error) {
+	var builder strings.Builder
+	for _, part := range expr.parts {
+		value, err := i.evaluate(part)
 		if err != nil {
 			return nil, err
 		}
-		value := left.(float64) * right.(float64)
-		return strconv.FormatFloat(value, 'f', -1, 64), nil
+		builder.WriteString(value.String())
 	}
-	// unreachable
-	return nil, nil
-}
-func (i *Interpreter) VisitGroupingExpr(expr Grouping) (interface{}, error) {
-	return i.evaluate(expr.expression)
+	err := i.allocate(expr.start, builder.Len())
+	if err != nil {
+		return nil, err
+	}
+	return StringValue(builder.String()), nil
 }
-func (i *Interpreter) VisitLiteralExpr(expr Literal) (interface{}, error) {
+func (i *Interpreter) VisitLiteralExpr(expr *Literal) (interface{}, error) {
 	return expr.value, nil
 
 }
-func (i *Interpreter) VisitUnaryExpr(expr Unary) (interface{}, error) {
-	var err error
-	var right interface{}
-	right, err = i.evaluate(expr.right)
+func (i *Interpreter) VisitUnaryExpr(expr *Unary) (interface{}, error) {
+	right, err := i.evaluate(expr.right)
 	if err != nil {
 		return nil, err
 	}
 	switch expr.operator.Type {
 	case BANG:
-		return !i.isTruthy(right), nil
+		return BoolValue(!right.IsTruthy()), nil
 	case MINUS:
 		err = checkNumberOperand(expr.operator, right)
 		if err != nil {
 			return nil, err
 		}
-		return strconv.FormatFloat(right.(float64), 'f', -1, 64), nil
+		return NumberValue(-right.AsNumber()), nil
 	}
 	// unreachable
 	return nil, nil
 }
-func (i *Interpreter) VisitVariableExpr(stmt Variable) (interface{}, error) {
-	return i.environment.Get(stmt.name)
+func (i *Interpreter) VisitVariableExpr(expr *Variable) (interface{}, error) {
+	return i.lookUpVariable(expr.name, expr)
 }
 
-func (i *Interpreter) VisitAssignExpr(stmt Assign) (interface{}, error) {
-	value, err := i.evaluate(stmt.value)
+func (i *Interpreter) VisitAssignExpr(expr *Assign) (interface{}, error) {
+	value, err := i.evaluate(expr.value)
+	if err != nil {
+		return nil, err
+	}
+	if local, isLocal := i.locals[expr]; isLocal {
+		i.environment.AssignAt(local.depth, local.slot, value)
+		return value, nil
+	}
+	err = i.globals.Assign(expr.name, value)
 	if err != nil {
 		return nil, err
 	}
-	i.environment.Assign(stmt.name, value)
 	return value, nil
 }
 
-func (i *Interpreter) VisitLogicalExpr(expr Logical) (interface{}, error) {
+func (i *Interpreter) lookUpVariable(name Token, expr Expr) (Value, error) {
+	if local, isLocal := i.locals[expr]; isLocal {
+		return i.environment.GetAt(local.depth, local.slot), nil
+	}
+	return i.globals.Get(name)
+}
+
+func (i *Interpreter) VisitLogicalExpr(expr *Logical) (interface{}, error) {
 	value, err := i.evaluate(expr.left)
 	if err != nil {
 		return nil, err
 	}
 	if expr.operator.Type == OR {
-		if i.isTruthy(value) {
+		if value.IsTruthy() {
 			return value, nil
 		}
 	} else {
-		if !i.isTruthy(value) {
+		if !value.IsTruthy() {
 			return value, nil
 		}
 	}
@@ -183,25 +319,45 @@ func (i *Interpreter) VisitLogicalExpr(expr Logical) (interface{}, error) {
 	return value, nil
 }
 
-func (i *Interpreter) VisitWhileStmt(stmt While) (interface{}, error) {
-	var value interface{}
-	var err error
+func (i *Interpreter) VisitWhileStmt(stmt *While) (interface{}, error) {
 	for {
-		value, err = i.evaluate(stmt.condition)
+		err := i.step(stmt.keyword)
+		if err != nil {
+			return nil, err
+		}
+		value, err := i.evaluate(stmt.condition)
 		if err != nil {
 			return nil, err
 		}
-		if !i.isTruthy(value) {
+		if !value.IsTruthy() {
 			return nil, nil
 		}
 		_, err = i.execute(stmt.body)
 		if err != nil {
-			return nil, err
+			switch err.(type) {
+			case BreakSignal:
+				return nil, nil
+			case ContinueSignal:
+			default:
+				return nil, err
+			}
+		}
+		if stmt.increment != nil {
+			_, err = i.evaluate(stmt.increment)
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 }
-func (i *Interpreter) VisitVarStmt(stmt Var) (interface{}, error) {
-	var value interface{}
+func (i *Interpreter) VisitBreakStmt(stmt *Break) (interface{}, error) {
+	return nil, BreakSignal{}
+}
+func (i *Interpreter) VisitContinueStmt(stmt *Continue) (interface{}, error) {
+	return nil, ContinueSignal{}
+}
+func (i *Interpreter) VisitVarStmt(stmt *Var) (interface{}, error) {
+	var value Value
 	var err error
 	if stmt.initializer != nil {
 		value, err = i.evaluate(stmt.initializer)
@@ -209,37 +365,74 @@ func (i *Interpreter) VisitVarStmt(stmt Var) (interface{}, error) {
 			return nil, err
 		}
 	}
+	err = i.allocate(stmt.name, variableBytes)
+	if err != nil {
+		return nil, err
+	}
 	i.environment.Define(stmt.name.Lexeme, value)
 	return nil, nil
 }
-func (i *Interpreter) VisitExpressionStmt(stmt Expression) (interface{}, error) {
+func (i *Interpreter) VisitExpressionStmt(stmt *Expression) (interface{}, error) {
 	_, err := i.evaluate(stmt.expression)
 	return nil, err
 }
-func (i *Interpreter) VisitBlockStmt(stmt Block) (interface{}, error) {
+func (i *Interpreter) VisitBlockStmt(stmt *Block) (interface{}, error) {
 	_, err := i.executeBlock(stmt.statements, NewEnvironment(i.environment))
 	return nil, err
 }
-func (i *Interpreter) VisitPrintStmt(stmt Print) (interface{}, error) {
+func (i *Interpreter) VisitPrintStmt(stmt *Print) (interface{}, error) {
 	value, err := i.evaluate(stmt.expression)
 	if err == nil {
-		fmt.Println(stringify(value))
+		fmt.Fprintln(i.stdout, value.String())
 		return nil, nil
 	} else {
 		return nil, err
 	}
 }
-func (i *Interpreter) VisitIfStmt(stmt If) (interface{}, error) {
+func (i *Interpreter) VisitClassStmt(stmt *Class) (interface{}, error) {
+	err := i.allocate(stmt.name, classBytes(len(stmt.methods)))
+	if err != nil {
+		return nil, err
+	}
+	methods := make(map[string]LoxFunction)
+	for _, method := range stmt.methods {
+		methods[method.name.Lexeme] = NewLoxFunction(method, i.environment, method.name.Lexeme == "init")
+	}
+	class := NewLoxClass(stmt.name.Lexeme, methods)
+	i.environment.Define(stmt.name.Lexeme, CallableValue(class))
+	return nil, nil
+}
+func (i *Interpreter) VisitFunctionStmt(stmt *Function) (interface{}, error) {
+	err := i.allocate(stmt.name, functionBytes)
+	if err != nil {
+		return nil, err
+	}
+	function := NewLoxFunction(stmt, i.environment, false)
+	i.environment.Define(stmt.name.Lexeme, CallableValue(function))
+	return nil, nil
+}
+func (i *Interpreter) VisitReturnStmt(stmt *Return) (interface{}, error) {
+	var value Value
+	var err error
+	if stmt.value != nil {
+		value, err = i.evaluate(stmt.value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, ReturnValue{Value: value}
+}
+func (i *Interpreter) VisitIfStmt(stmt *If) (interface{}, error) {
 	value, err := i.evaluate(stmt.condition)
 	if err != nil {
 		return nil, err
 	}
-	if i.isTruthy(value) {
+	if value.IsTruthy() {
 		_, err = i.execute(stmt.thenBranch)
 		if err != nil {
 			return nil, err
 		}
-	} else if stmt.thenBranch != nil {
+	} else if stmt.elseBranch != nil {
 		_, err = i.execute(stmt.elseBranch)
 		if err != nil {
 			return nil, err
@@ -253,8 +446,13 @@ func (i *Interpreter) execute(stmt Stmt) (interface{}, error) {
 }
 
 func (i *Interpreter) executeBlock(statements []Stmt, environment *Environment) (interface{}, error) {
-
-	previous := *i.environment
+	previous := i.environment
+	i.scopes = append(i.scopes, previous)
+	// Restore the enclosing scope even when a statement errors or returns.
+	defer func() {
+		i.environment = previous
+		i.scopes = i.scopes[:len(i.scopes)-1]
+	}()
 	i.environment = environment
 	for _, statement := range statements {
 		_, err := i.execute(statement)
@@ -262,73 +460,32 @@ func (i *Interpreter) executeBlock(statements []Stmt, environment *Environment)
 			return nil, err
 		}
 	}
-	i.environment = &previous
 	return nil, nil
 }
 
-func (i *Interpreter) evaluate(expr Expr) (interface{}, error) {
-	return expr.Accept(i)
-}
-
-func (i *Interpreter) isTruthy(object interface{}) bool {
-	if object == nil {
-		return false
-	}
-	if object == false {
-		return false
-	}
-	return true
+func (i *Interpreter) resolve(expr Expr, depth int, slot int) {
+	i.locals[expr] = binding{depth: depth, slot: slot}
 }
 
-func isNumber(object interface{}) bool {
-	switch object.(type) {
-	case int:
-		return true
-	case float32, float64:
-		return true
-	default:
-		return false
-	}
-}
-
-func isEqual(left interface{}, right interface{}) bool {
-	if left == nil && right == nil {
-		return true
-	}
-	if left == nil {
-		return false
+// evaluate unwraps the Value every expression visitor returns.
+func (i *Interpreter) evaluate(expr Expr) (Value, error) {
+	value, err := expr.Accept(i)
+	if err != nil {
+		return Value{}, err
 	}
-	return left == right
+	return value.(Value), nil
 }
 
-func checkNumberOperands(operator Token, left interface{}, right interface{}) error {
-	if isNumber(left) && isNumber(right) {
+func checkNumberOperands(operator Token, left Value, right Value) error {
+	if left.IsNumber() && right.IsNumber() {
 		return nil
 	}
-	return &RuntimeError{Operator: operator, Message: "Operands must be a number."}
-
+	return RuntimeError{Operator: operator, Message: "Operands must be numbers."}
 }
-func checkNumberOperand(operator Token, operand interface{}) error {
-	if isNumber(operand) {
-		return nil
-	}
-	return &RuntimeError{Operator: operator, Message: "Operands must be a number."}
 
-}
-
-func stringify(object interface{}) string {
-	if object == nil {
-		return "nil"
-	}
-
-	switch value := object.(type) {
-	case int:
-		return strconv.Itoa(value)
-	case float64:
-		return strconv.FormatFloat(value, 'f', -1, 64)
-	case string:
-		return fmt.Sprintf("\"%v\"", object)
-	default:
-		return fmt.Sprintf("Unknown type")
+func checkNumberOperand(operator Token, operand Value) error {
+	if operand.IsNumber() {
+		return nil
 	}
+	return RuntimeError{Operator: operator, Message: "Operand must be a number."}
 }
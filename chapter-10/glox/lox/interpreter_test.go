@@ -0,0 +1,100 @@
+package lox
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+const nestedLoops = `
+var total = 0;
+for (var i = 0; i < 200; i = i + 1) {
+  var step = 1;
+  for (var j = 0; j < 200; j = j + step) {
+    total = total + j;
+  }
+}
+`
+
+const closureCalls = `
+fun makeAdder(n) {
+  fun add(x) {
+    return x + n;
+  }
+  return add;
+}
+var addOne = makeAdder(1);
+var k = 0;
+while (k < 20000) {
+  k = addOne(k);
+}
+`
+
+func BenchmarkControlFlowExample(b *testing.B) {
+	source, err := os.ReadFile("../examples/control-flow.lox")
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkScript(b, string(source))
+}
+
+func BenchmarkNestedLoops(b *testing.B) {
+	benchmarkScript(b, nestedLoops)
+}
+
+func BenchmarkClosureCalls(b *testing.B) {
+	benchmarkScript(b, closureCalls)
+}
+
+func BenchmarkVMNestedLoops(b *testing.B) {
+	benchmarkVM(b, nestedLoops)
+}
+
+func BenchmarkVMClosureCalls(b *testing.B) {
+	benchmarkVM(b, closureCalls)
+}
+
+// benchmarkScript parses and resolves source once, then times repeated
+// interpretation with print output discarded.
+func benchmarkScript(b *testing.B, source string) {
+	collector := NewDiagnosticCollector("")
+	scanner := NewScanner(source, collector)
+	parser := NewParser(scanner.ScanTokens(), collector)
+	statements := parser.Parse()
+	interpreter := NewInterpreter()
+	interpreter.SetOutput(io.Discard)
+	resolver := NewResolver(interpreter, collector)
+	resolver.Resolve(statements)
+	if collector.HadError() {
+		b.Fatal(collector.Diagnostics)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		err := interpreter.Interpret(statements)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkVM compiles source once, then times repeated runs of the
+// bytecode on a VM.
+func benchmarkVM(b *testing.B, source string) {
+	collector := NewDiagnosticCollector("")
+	statements := analyze(source, nil, collector)
+	function := newCompiler(collector).compile(statements)
+	if collector.HadError() {
+		b.Fatal(collector.Diagnostics)
+	}
+	vm := NewVM()
+	vm.SetOutput(io.Discard)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		err := vm.interpret(function)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package lox
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Limits bounds what a script run by an Interpreter may do. A zero field
+// means no limit, except for MaxCallDepth: unbounded recursion would
+// overflow the Go stack, so zero means the VM's own frame limit instead.
+//
+// A step is one loop iteration or one call. Everything else a script does
+// is bounded by its length, so MaxSteps is enough to stop it from running
+// forever. MaxHeapBytes caps an estimate of the memory a run holds. The
+// tree-walker leaves freeing memory to Go, so it counts what a run
+// allocates and, once that passes the limit, measures what is still
+// reachable instead, failing only if that is over the limit too.
+type Limits struct {
+	MaxSteps     int
+	MaxCallDepth int
+	MaxHeapBytes int
+}
+
+// usage is what the current run has spent against its Limits. heapBytes is
+// what was reachable when last measured plus what has been allocated since.
+type usage struct {
+	steps     int
+	depth     int
+	heapBytes int
+}
+
+// SetLimits replaces the limits applied to every later run.
+func (i *Interpreter) SetLimits(limits Limits) {
+	i.limits = limits
+}
+
+// step spends one step at location and reports cancellation, which is only
+// checked here since every unbounded computation passes through a step.
+func (i *Interpreter) step(location Token) error {
+	i.usage.steps++
+	if i.limits.MaxSteps > 0 && i.usage.steps > i.limits.MaxSteps {
+		return RuntimeError{Operator: location, Message: "Step limit exceeded.", Kind: RUNTIME_STEP_LIMIT}
+	}
+	select {
+	case <-i.ctx.Done():
+		return RuntimeError{Operator: location, Message: fmt.Sprintf("Execution cancelled: %v.", i.ctx.Err()), Kind: RUNTIME_CANCELLED}
+	default:
+		return nil
+	}
+}
+
+// enterCall spends a step on calling function and charges for what the
+// call allocates. The caller decrements usage.depth when the call returns.
+func (i *Interpreter) enterCall(paren Token, function LoxCallable) error {
+	err := i.step(paren)
+	if err != nil {
+		return err
+	}
+	maxDepth := i.limits.MaxCallDepth
+	if maxDepth == 0 {
+		maxDepth = framesMax
+	}
+	if i.usage.depth >= maxDepth {
+		return RuntimeError{Operator: paren, Message: "Stack overflow.", Kind: RUNTIME_STACK_OVERFLOW}
+	}
+	err = i.allocate(paren, callBytes(function))
+	if err != nil {
+		return err
+	}
+	i.usage.depth++
+	return nil
+}
+
+// allocate charges bytes against MaxHeapBytes, measuring the heap before
+// giving up in case garbage has made room.
+func (i *Interpreter) allocate(location Token, bytes int) error {
+	i.usage.heapBytes += bytes
+	if i.limits.MaxHeapBytes == 0 || i.usage.heapBytes <= i.limits.MaxHeapBytes {
+		return nil
+	}
+	i.usage.heapBytes = i.measureHeap() + bytes
+	if i.usage.heapBytes > i.limits.MaxHeapBytes {
+		return RuntimeError{Operator: location, Message: "Heap limit exceeded.", Kind: RUNTIME_HEAP_LIMIT}
+	}
+	return nil
+}
+
+// measureHeap estimates the bytes still reachable from the globals and the
+// scopes in use, tracing them as a collector's mark phase would. Values
+// held only partway through evaluating an expression are missed.
+func (i *Interpreter) measureHeap() int {
+	measure := heapMeasure{seen: make(map[interface{}]bool)}
+	measure.environment(i.globals)
+	measure.environment(i.environment)
+	for _, scope := range i.scopes {
+		measure.environment(scope)
+	}
+	return measure.bytes
+}
+
+// heapMeasure totals the estimated sizes of what it has traced, counting
+// each environment, object and string once.
+type heapMeasure struct {
+	seen  map[interface{}]bool
+	bytes int
+}
+
+// mark reports whether key is new, remembering it.
+func (m *heapMeasure) mark(key interface{}) bool {
+	if m.seen[key] {
+		return false
+	}
+	m.seen[key] = true
+	return true
+}
+
+func (m *heapMeasure) environment(environment *Environment) {
+	for ; environment != nil && m.mark(environment); environment = environment.enclosing {
+		m.bytes += environmentBytes(len(environment.slots))
+		for name, value := range environment.values {
+			m.bytes += entrySize(name)
+			m.value(value)
+		}
+		for _, value := range environment.slots {
+			m.value(value)
+		}
+	}
+}
+
+func (m *heapMeasure) value(value Value) {
+	switch {
+	case value.IsString():
+		// Copies of a string share its bytes.
+		if text := value.AsString(); text != "" && m.mark(unsafe.StringData(text)) {
+			m.bytes += len(text)
+		}
+	case value.IsCallable():
+		m.callable(value.AsCallable())
+	case value.IsObject():
+		if instance, isInstance := value.AsObject().(*LoxInstance); isInstance && m.mark(instance) {
+			m.bytes += int(unsafe.Sizeof(LoxInstance{}))
+			for name, field := range instance.fields {
+				m.bytes += entrySize(name)
+				m.value(field)
+			}
+			m.callable(instance.class)
+		}
+	}
+}
+
+func (m *heapMeasure) callable(callable Callable) {
+	switch callable := callable.(type) {
+	case LoxFunction:
+		if m.mark(callable) {
+			m.bytes += functionBytes
+			m.environment(callable.closure)
+		}
+	case *LoxClass:
+		if m.mark(callable) {
+			m.bytes += classBytes(len(callable.methods))
+			for _, method := range callable.methods {
+				m.environment(method.closure)
+			}
+		}
+	}
+}
+
+// Estimated sizes of what the interpreter allocates.
+var (
+	variableBytes    = int(unsafe.Sizeof(Value{}))
+	functionBytes    = int(unsafe.Sizeof(LoxFunction{})) + variableBytes
+	boundMethodBytes = environmentBytes(1) + int(unsafe.Sizeof(LoxFunction{}))
+)
+
+func environmentBytes(slots int) int {
+	return int(unsafe.Sizeof(Environment{})) + slots*variableBytes
+}
+
+func classBytes(methods int) int {
+	return int(unsafe.Sizeof(LoxClass{})) + methods*int(unsafe.Sizeof(LoxFunction{})) + variableBytes
+}
+
+// callBytes is what calling function allocates: an environment for its
+// parameters and, when it is a class, the new instance.
+func callBytes(function LoxCallable) int {
+	switch function.(type) {
+	case *NativeFunction:
+		return 0
+	case *LoxClass:
+		return int(unsafe.Sizeof(LoxInstance{})) + environmentBytes(function.Arity())
+	default:
+		return environmentBytes(function.Arity())
+	}
+}
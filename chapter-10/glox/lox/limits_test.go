@@ -0,0 +1,118 @@
+package lox
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// interpretWithLimits parses, resolves and runs source under limits and
+// returns the runtime error it stopped with.
+func interpretWithLimits(t *testing.T, ctx context.Context, limits Limits, source string) RuntimeError {
+	t.Helper()
+	interpreter := NewInterpreter()
+	interpreter.SetLimits(limits)
+	collector := NewDiagnosticCollector("")
+	statements := analyze(source, interpreter, collector)
+	if collector.HadError() {
+		t.Fatal(collector.Diagnostics)
+	}
+	err := interpreter.InterpretContext(ctx, statements)
+	runtimeError, isRuntimeError := err.(RuntimeError)
+	if !isRuntimeError {
+		t.Fatalf("expected a runtime error, got %v", err)
+	}
+	return runtimeError
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits Limits
+		source string
+		kind   RuntimeErrorKind
+		line   int
+	}{
+		{
+			name:   "steps",
+			limits: Limits{MaxSteps: 1000},
+			source: "var i = 0;\nwhile (true) { i = i + 1; }",
+			kind:   RUNTIME_STEP_LIMIT,
+			line:   2,
+		},
+		{
+			name:   "default call depth",
+			source: "fun recurse() {\n  recurse();\n}\nrecurse();",
+			kind:   RUNTIME_STACK_OVERFLOW,
+			line:   2,
+		},
+		{
+			name:   "call depth",
+			limits: Limits{MaxCallDepth: 10},
+			source: "fun count(n) {\n  return count(n + 1);\n}\ncount(0);",
+			kind:   RUNTIME_STACK_OVERFLOW,
+			line:   2,
+		},
+		{
+			name:   "heap",
+			limits: Limits{MaxHeapBytes: 4096},
+			source: "var s = \"x\";\nwhile (true) {\n  s = s + s;\n}",
+			kind:   RUNTIME_HEAP_LIMIT,
+			line:   3,
+		},
+		{
+			name:   "heap held by objects",
+			limits: Limits{MaxHeapBytes: 1 << 16},
+			source: "class Node {}\nvar head = nil;\nwhile (true) {\n  var node = Node();\n  node.next = head;\n  head = node;\n}",
+			kind:   RUNTIME_HEAP_LIMIT,
+			line:   5,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			err := interpretWithLimits(t, context.Background(), test.limits, test.source)
+			if err.Kind != test.kind || err.Operator.Line != test.line {
+				t.Errorf("got %v (kind %d) on line %d, want kind %d on line %d", err.Message, err.Kind, err.Operator.Line, test.kind, test.line)
+			}
+		})
+	}
+}
+
+func TestInterpretContextCancels(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := interpretWithLimits(t, ctx, Limits{}, "while (true) {}")
+	if err.Kind != RUNTIME_CANCELLED {
+		t.Errorf("got %v (kind %d), want a cancellation", err.Message, err.Kind)
+	}
+}
+
+// TestHeapLimitAllowsSteadyUse checks MaxHeapBytes bounds what a run holds
+// rather than what it allocates, so garbage does not count against it.
+func TestHeapLimitAllowsSteadyUse(t *testing.T) {
+	sources := map[string]string{
+		"locals":  "for (var n = 0; n < 100000; n = n + 1) { var x = n; }",
+		"strings": "var s = \"\";\nfor (var n = 0; n < 10000; n = n + 1) { s = \"abc\" + \"def\"; }",
+		"closures": `
+fun counter() {
+  var count = 0;
+  fun increment() { count = count + 1; return count; }
+  return increment;
+}
+for (var n = 0; n < 10000; n = n + 1) { counter()(); }`,
+		"instances": "class Point {}\nfor (var n = 0; n < 10000; n = n + 1) { var p = Point(); p.x = n; }",
+	}
+	for name, source := range sources {
+		interpreter := NewInterpreter()
+		interpreter.SetLimits(Limits{MaxHeapBytes: 1 << 20})
+		collector := NewDiagnosticCollector("")
+		statements := analyze(source, interpreter, collector)
+		if collector.HadError() {
+			t.Fatal(collector.Diagnostics)
+		}
+		if err := interpreter.Interpret(statements); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package lox
+
+// BreakSignal and ContinueSignal travel up through executeBlock as errors,
+// the same way ReturnValue does, until the innermost VisitWhileStmt
+// handles them.
+type BreakSignal struct{}
+
+func (b BreakSignal) Error() string {
+	return "break outside of loop"
+}
+
+type ContinueSignal struct{}
+
+func (c ContinueSignal) Error() string {
+	return "continue outside of loop"
+}
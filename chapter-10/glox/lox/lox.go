@@ -1,94 +1,113 @@
-package main
+// Package lox runs the Lox language from Crafting Interpreters, either on a
+// tree-walking Interpreter or compiled to bytecode for a VM. It keeps no
+// process-global state, so any number of interpreters can be embedded in
+// one program.
+package lox
 
-import (
-	"bufio"
-	"fmt"
-	"log"
-	"os"
-)
+import "context"
 
-var hadError = false
-var hadRuntimeError = false
-var interpreter = NewInterpreter()
+type Result int
 
-func LoxMain(args []string) {
-	length := len(args)
+const (
+	INTERPRET_OK Result = iota
+	INTERPRET_COMPILE_ERROR
+	INTERPRET_RUNTIME_ERROR
+)
 
-	if length > 1 {
-		fmt.Println("Usage: glox [script]")
-		os.Exit(64)
-	} else if length == 1 {
-		runFile(args[0])
-	} else {
-		runPrompt()
-	}
+// Run interprets source with a fresh interpreter.
+func Run(source string) (Result, []Diagnostic) {
+	return NewInterpreter().Run(source)
 }
 
-func runFile(filePath string) {
-	// Read the file content
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		log.Fatalf("Error reading file: %v", err)
-	}
+// Run scans, parses, resolves and interprets source. Globals defined by
+// earlier calls stay visible, which is what the REPL relies on.
+func (i *Interpreter) Run(source string) (Result, []Diagnostic) {
+	return i.RunSource("", source)
+}
 
-	run(string(content))
-	if hadError {
-		os.Exit(65)
-	}
-	if hadRuntimeError {
-		os.Exit(70)
-	}
+// RunSource is Run for source read from file, which every diagnostic is
+// attributed to.
+func (i *Interpreter) RunSource(file string, source string) (Result, []Diagnostic) {
+	return i.RunSourceContext(context.Background(), file, source)
 }
 
-func runPrompt() {
-	scanner := bufio.NewScanner(os.Stdin)
-	for {
-		fmt.Print("> ")
+// RunSourceContext is RunSource that stops running once ctx is done.
+func (i *Interpreter) RunSourceContext(ctx context.Context, file string, source string) (Result, []Diagnostic) {
+	return i.runSource(ctx, file, source, false)
+}
 
-		if !scanner.Scan() {
-			scanner.Err()
-			break
-		}
-		line := scanner.Text()
-		run(line)
-		hadError = false
-	}
+// RunPrompt is Run for a line typed at the REPL. The last expression
+// statement may leave off its semicolon, and the value of every top-level
+// expression statement other than an assignment is printed.
+func (i *Interpreter) RunPrompt(source string) (Result, []Diagnostic) {
+	return i.runSource(context.Background(), "", source, true)
 }
 
-func run(source string) {
-	scanner := NewScanner(source)
-	tokens := scanner.ScanTokens()
-	// fmt.Println("Tokens: ")
-	// for _, token := range tokens {
-	// 	fmt.Printf("Type: %-12s Lexeme: %-10s Literal: %v \n", TokenName[token.Type], token.Lexeme, token.Literal)
-	// }
-	// fmt.Println("")
-	parser := NewParser(tokens)
-	statements := parser.Parse()
-	if hadError {
-		return
+func (i *Interpreter) runSource(ctx context.Context, file string, source string, prompt bool) (Result, []Diagnostic) {
+	collector := NewDiagnosticCollector(file)
+	statements := analyzeSource(source, i, collector, prompt)
+	if collector.HadError() {
+		return INTERPRET_COMPILE_ERROR, collector.Diagnostics
 	}
-	interpreter.Interpret(statements)
-}
 
-func Error(line int, message string) {
-	report(line, "", message)
+	err := i.InterpretContext(ctx, statements)
+	if runtimeError, isRuntimeError := err.(RuntimeError); isRuntimeError {
+		collector.Report(newRuntimeDiagnostic(runtimeError))
+		return INTERPRET_RUNTIME_ERROR, collector.Diagnostics
+	}
+	return INTERPRET_OK, collector.Diagnostics
 }
 
-func TokenError(token Token, message string) {
-	if token.Type == EOF {
-		report(token.Line, " at the end ", message)
-	} else {
-		report(token.Line, " at '"+token.Lexeme+"'", message)
+// IsIncomplete reports whether source stops partway through a statement,
+// so that a REPL should read another line before running it.
+func IsIncomplete(source string) bool {
+	collector := NewDiagnosticCollector("")
+	scanner := NewScanner(source, collector)
+	parser := NewParser(scanner.ScanTokens(), collector)
+	parser.prompt = true
+	parser.Parse()
+	if len(collector.Diagnostics) == 0 {
+		return false
 	}
+	// Later errors may only be fallout from the first.
+	first := collector.Diagnostics[0]
+	return first.Where == " at end" || first.Message == "Unterminated string." ||
+		first.Message == "Unterminated comment."
 }
 
-func report(line int, where string, message string) {
-	fmt.Fprintf(os.Stderr, "[line %d] Error %s: %s\n", line, where, message)
-	hadError = true
+// analyze scans, parses and resolves source, reporting every static error
+// to collector. Locals are bound in interpreter unless it is nil.
+func analyze(source string, interpreter *Interpreter, collector *DiagnosticCollector) []Stmt {
+	return analyzeSource(source, interpreter, collector, false)
 }
 
-func ReportRuntimeError(err RuntimeError) {
-	fmt.Fprintf(os.Stderr, "%s\n[line %d]\n", err.Message, err.Operator.Line)
-	hadRuntimeError = true
+// analyzeSource is analyze that, for a prompt, parses source the way
+// RunPrompt describes and turns the expression statements to echo into
+// print statements.
+func analyzeSource(source string, interpreter *Interpreter, collector *DiagnosticCollector, prompt bool) []Stmt {
+	scanner := NewScanner(source, collector)
+	tokens := scanner.ScanTokens()
+	parser := NewParser(tokens, collector)
+	parser.prompt = prompt
+	statements := parser.Parse()
+	if collector.HadError() {
+		return nil
+	}
+	if prompt {
+		for index, statement := range statements {
+			expression, isExpression := statement.(*Expression)
+			if !isExpression {
+				continue
+			}
+			switch expression.expression.(type) {
+			case *Assign, *Set:
+			default:
+				statements[index] = NewPrint(expression.expression)
+			}
+		}
+	}
+
+	resolver := NewResolver(interpreter, collector)
+	resolver.Resolve(statements)
+	return statements
 }
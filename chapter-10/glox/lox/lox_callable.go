@@ -0,0 +1,6 @@
+package lox
+
+type LoxCallable interface {
+	Callable
+	Call(interpreter *Interpreter, arguments []Value) (Value, error)
+}
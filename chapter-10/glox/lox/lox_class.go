@@ -0,0 +1,44 @@
+package lox
+
+type LoxClass struct {
+	name    string
+	methods map[string]LoxFunction
+}
+
+func NewLoxClass(name string, methods map[string]LoxFunction) *LoxClass {
+	return &LoxClass{
+		name:    name,
+		methods: methods,
+	}
+}
+
+func (c *LoxClass) findMethod(name string) (LoxFunction, bool) {
+	method, exists := c.methods[name]
+	return method, exists
+}
+
+// Arity is the arity of the initializer, if the class declares one.
+func (c *LoxClass) Arity() int {
+	initializer, exists := c.findMethod("init")
+	if !exists {
+		return 0
+	}
+	return initializer.Arity()
+}
+
+// Call constructs a new instance and runs its initializer.
+func (c *LoxClass) Call(interpreter *Interpreter, arguments []Value) (Value, error) {
+	instance := NewLoxInstance(c)
+	initializer, exists := c.findMethod("init")
+	if exists {
+		_, err := initializer.bind(instance).Call(interpreter, arguments)
+		if err != nil {
+			return Value{}, err
+		}
+	}
+	return ObjectValue(instance), nil
+}
+
+func (c *LoxClass) String() string {
+	return c.name
+}
@@ -0,0 +1,54 @@
+package lox
+
+type LoxFunction struct {
+	declaration   *Function
+	closure       *Environment
+	isInitializer bool
+}
+
+func NewLoxFunction(declaration *Function, closure *Environment, isInitializer bool) LoxFunction {
+	return LoxFunction{
+		declaration:   declaration,
+		closure:       closure,
+		isInitializer: isInitializer,
+	}
+}
+
+// bind returns a copy of the method whose closure defines "this" as the
+// given instance. The resolver reserves slot 0 of that scope for it.
+func (f LoxFunction) bind(instance *LoxInstance) LoxFunction {
+	environment := NewEnvironment(f.closure)
+	environment.Define("this", ObjectValue(instance))
+	return NewLoxFunction(f.declaration, environment, f.isInitializer)
+}
+
+func (f LoxFunction) Arity() int {
+	return len(f.declaration.params)
+}
+
+func (f LoxFunction) Call(interpreter *Interpreter, arguments []Value) (Value, error) {
+	environment := NewEnvironment(f.closure)
+	for i, param := range f.declaration.params {
+		environment.Define(param.Lexeme, arguments[i])
+	}
+	_, err := interpreter.executeBlock(f.declaration.body, environment)
+	if err != nil {
+		// A return statement unwinds the call stack as an error.
+		returnValue, isReturn := err.(ReturnValue)
+		if !isReturn {
+			return Value{}, err
+		}
+		if f.isInitializer {
+			return f.closure.GetAt(0, 0), nil
+		}
+		return returnValue.Value, nil
+	}
+	if f.isInitializer {
+		return f.closure.GetAt(0, 0), nil
+	}
+	return NilValue(), nil
+}
+
+func (f LoxFunction) String() string {
+	return "<fn " + f.declaration.name.Lexeme + ">"
+}
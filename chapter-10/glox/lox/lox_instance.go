@@ -0,0 +1,32 @@
+package lox
+
+type LoxInstance struct {
+	class  *LoxClass
+	fields map[string]Value
+}
+
+func NewLoxInstance(class *LoxClass) *LoxInstance {
+	return &LoxInstance{
+		class:  class,
+		fields: make(map[string]Value),
+	}
+}
+
+// Get looks up a field first, so fields shadow methods of the same name.
+func (i *LoxInstance) Get(name Token) (Value, error) {
+	if value, exists := i.fields[name.Lexeme]; exists {
+		return value, nil
+	}
+	if method, exists := i.class.findMethod(name.Lexeme); exists {
+		return CallableValue(method.bind(i)), nil
+	}
+	return Value{}, RuntimeError{Operator: name, Message: "Undefined property '" + name.Lexeme + "'."}
+}
+
+func (i *LoxInstance) Set(name Token, value Value) {
+	i.fields[name.Lexeme] = value
+}
+
+func (i *LoxInstance) String() string {
+	return i.class.name + " instance"
+}
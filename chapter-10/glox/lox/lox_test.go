@@ -0,0 +1,114 @@
+package lox
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// embedder is what a host program needs of a backend.
+type embedder interface {
+	Run(source string) (Result, []Diagnostic)
+	SetOutput(w io.Writer)
+	DefineNative(name string, arity int, fn func(host Host, arguments []Value) (Value, error))
+}
+
+func TestFailingNative(t *testing.T) {
+	embedders := []struct {
+		name string
+		new  func() embedder
+	}{
+		{"interpreter", func() embedder { return NewInterpreter() }},
+		{"vm", func() embedder { return NewVM() }},
+	}
+	for _, embedder := range embedders {
+		var output bytes.Buffer
+		runner := embedder.new()
+		runner.SetOutput(&output)
+		runner.DefineNative("boom", 0, func(host Host, arguments []Value) (Value, error) {
+			return NilValue(), errors.New("boom went wrong")
+		})
+		result, diagnostics := runner.Run("print 1;\nboom();\nprint 2;")
+		if result != INTERPRET_RUNTIME_ERROR {
+			t.Errorf("%s: got result %v, want a runtime error", embedder.name, result)
+		}
+		if output.String() != "1\n" {
+			t.Errorf("%s: got output %q, want the script to stop after the failing call", embedder.name, output.String())
+		}
+		if len(diagnostics) != 1 || diagnostics[0].Message != "boom went wrong" || diagnostics[0].Line != 2 {
+			t.Errorf("%s: got diagnostics %v, want the native's error on line 2", embedder.name, diagnostics)
+		}
+	}
+}
+
+// prompter is what the REPL needs of a backend.
+type prompter interface {
+	RunPrompt(source string) (Result, []Diagnostic)
+	SetOutput(w io.Writer)
+}
+
+func TestRunPrompt(t *testing.T) {
+	prompters := []struct {
+		name string
+		new  func() prompter
+	}{
+		{"interpreter", func() prompter { return NewInterpreter() }},
+		{"vm", func() prompter { return NewVM() }},
+	}
+	// Each line is run at the same prompt, so state carries over.
+	lines := []string{
+		"1 + 2",
+		"var a = 1;",
+		"a = a + 1;",
+		"a",
+		"fun f() { return a * 10; } f(); \"two\";",
+		"{ a; }",
+		"print a",
+	}
+	want := "3\n2\n20\ntwo\n"
+	for _, prompter := range prompters {
+		var output bytes.Buffer
+		runner := prompter.new()
+		runner.SetOutput(&output)
+		var diagnostics []Diagnostic
+		for _, line := range lines {
+			_, lineDiagnostics := runner.RunPrompt(line)
+			diagnostics = append(diagnostics, lineDiagnostics...)
+		}
+		if output.String() != want {
+			t.Errorf("%s output mismatch\n--- got ---\n%s--- want ---\n%s", prompter.name, output.String(), want)
+		}
+		if len(diagnostics) != 1 || diagnostics[0].Message != "Expect ';' after value." {
+			t.Errorf("%s: got diagnostics %v, want only the missing ';' after print", prompter.name, diagnostics)
+		}
+	}
+}
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"1 + 2", false},
+		{"print 1;", false},
+		{"print 1", true},
+		{"{", true},
+		{"for (var i = 0; i < 3; i = i + 1) {\n  print i;", true},
+		{"for (var i = 0; i < 3; i = i + 1) {\n  print i;\n}", false},
+		{"fun f(a,", true},
+		{"print \"unfinished", true},
+		{"print \"a ${b", true},
+		{"print \"a ${b} c", true},
+		{"print \"a ${b}\";", false},
+		{"1 +", true},
+		{"var = 1; {", false},
+		{"/* a /* nested", true},
+		{"/* a /* nested */ comment */ print 1;", false},
+	}
+	for _, test := range tests {
+		if got := IsIncomplete(test.source); got != test.want {
+			t.Errorf("IsIncomplete(%q) = %v, want %v", test.source, got, test.want)
+		}
+	}
+}
@@ -1,9 +0,0 @@
-package main
-
-import (
-	"os"
-)
-
-func main() {
-	LoxMain(os.Args[1:])
-}
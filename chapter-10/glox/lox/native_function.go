@@ -0,0 +1,61 @@
+package lox
+
+import "time"
+
+// NativeFunction wraps a Go function so Lox code can call it like any other
+// LoxCallable.
+type NativeFunction struct {
+	name  string
+	arity int
+	fn    func(host Host, arguments []Value) (Value, error)
+}
+
+func NewNativeFunction(name string, arity int, fn func(host Host, arguments []Value) (Value, error)) *NativeFunction {
+	return &NativeFunction{
+		name:  name,
+		arity: arity,
+		fn:    fn,
+	}
+}
+
+func (n *NativeFunction) Arity() int {
+	return n.arity
+}
+
+func (n *NativeFunction) Call(interpreter *Interpreter, arguments []Value) (Value, error) {
+	return n.fn(interpreter, arguments)
+}
+
+// nativeError turns an error returned by a native function into a runtime
+// error built by at, so it stops the script and is reported like any other.
+// A RuntimeError is passed through unchanged.
+func nativeError(err error, at func(message string) RuntimeError) error {
+	if _, isRuntimeError := err.(RuntimeError); isRuntimeError {
+		return err
+	}
+	return at(err.Error())
+}
+
+func (n *NativeFunction) String() string {
+	return "<native fn>"
+}
+
+// natives are the built-in functions every interpreter and VM starts with.
+var natives = []*NativeFunction{
+	NewNativeFunction("clock", 0, func(host Host, arguments []Value) (Value, error) {
+		return NumberValue(float64(time.Now().UnixNano()) / float64(time.Second)), nil
+	}),
+	NewNativeFunction("readLine", 0, func(host Host, arguments []Value) (Value, error) {
+		line, exists := host.ReadLine()
+		if !exists {
+			return NilValue(), nil
+		}
+		return StringValue(line), nil
+	}),
+}
+
+func defineNatives(interpreter *Interpreter) {
+	for _, native := range natives {
+		interpreter.globals.Define(native.name, CallableValue(native))
+	}
+}
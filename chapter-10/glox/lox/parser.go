@@ -1,48 +1,103 @@
-package main
+package lox
 
 import (
 	"errors"
 )
 
 type Parser struct {
-	tokens  []Token
-	current int
+	tokens   []Token
+	current  int
+	reporter ErrorReporter
+	// prompt lets the last expression statement leave off its semicolon,
+	// as a line typed at the REPL may.
+	prompt bool
 }
 
-func NewParser(tokens []Token) Parser {
+// NewParser returns a parser for tokens, leaving out the trivia among them.
+func NewParser(tokens []Token, reporter ErrorReporter) Parser {
+	var significant []Token
+	for _, token := range tokens {
+		if token.Type != DOC_COMMENT {
+			significant = append(significant, token)
+		}
+	}
 	return Parser{
-		tokens:  tokens,
-		current: 0,
+		tokens:   significant,
+		current:  0,
+		reporter: reporter,
 	}
 }
 
 func (p *Parser) Parse() []Stmt {
 	var statements []Stmt
 	for !p.isAtEnd() {
-		stmt, err := p.declaration()
-		if err == nil {
+		stmt := p.declaration()
+		if stmt != nil {
 			statements = append(statements, stmt)
 		}
 	}
 	return statements
 }
-func (p *Parser) declaration() (Stmt, error) {
+
+// ParseExpression parses tokens that should hold a single expression, as
+// the REPL's :ast command is given. It returns nil after a syntax error.
+func (p *Parser) ParseExpression() Expr {
+	expr, err := p.expression()
+	if err != nil {
+		return nil
+	}
+	if !p.isAtEnd() {
+		p.error(p.peek(), "Expect end of expression.")
+		return nil
+	}
+	return expr
+}
+
+// declaration parses a single declaration. After a syntax error it
+// synchronizes to the next statement boundary and returns nil, so parsing
+// carries on and every independent error in the source gets reported.
+func (p *Parser) declaration() Stmt {
 	var err error
 	var result Stmt
-	if p.match(VAR) {
+	if p.match(CLASS) {
+		result, err = p.classDeclaration()
+	} else if p.match(FUN) {
+		result, err = p.function("function")
+	} else if p.match(VAR) {
 		result, err = p.varDeclaration()
+	} else {
+		result, err = p.statement()
+	}
+	if err != nil {
+		p.synchronize()
+		return nil
+	}
+	return result
+}
+
+func (p *Parser) classDeclaration() (Stmt, error) {
+	err := p.consume(IDENTIFIER, "Expect class name.")
+	if err != nil {
+		return nil, err
+	}
+	name := p.previous()
+	err = p.consume(LEFT_BRACE, "Expect '{' before class body.")
+	if err != nil {
+		return nil, err
+	}
+	var methods []*Function
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		method, err := p.function("method")
 		if err != nil {
-			p.synchronize()
-			return nil, nil
+			return nil, err
 		}
-		return result, nil
+		methods = append(methods, method)
 	}
-	result, err = p.statement()
+	err = p.consume(RIGHT_BRACE, "Expect '}' after class body.")
 	if err != nil {
-		p.synchronize()
-		return nil, nil
+		return nil, err
 	}
-	return result, nil
+	return NewClass(name, methods), nil
 }
 
 func (p *Parser) varDeclaration() (Stmt, error) {
@@ -64,7 +119,54 @@ func (p *Parser) varDeclaration() (Stmt, error) {
 	}
 	return NewVar(name, initializer), nil
 }
+
+func (p *Parser) function(kind string) (*Function, error) {
+	err := p.consume(IDENTIFIER, "Expect "+kind+" name.")
+	if err != nil {
+		return nil, err
+	}
+	name := p.previous()
+	err = p.consume(LEFT_PAREN, "Expect '(' after "+kind+" name.")
+	if err != nil {
+		return nil, err
+	}
+	var parameters []Token
+	if !p.check(RIGHT_PAREN) {
+		for {
+			if len(parameters) >= 255 {
+				p.error(p.peek(), "Can't have more than 255 parameters.")
+			}
+			err = p.consume(IDENTIFIER, "Expect parameter name.")
+			if err != nil {
+				return nil, err
+			}
+			parameters = append(parameters, p.previous())
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	err = p.consume(RIGHT_PAREN, "Expect ')' after parameters.")
+	if err != nil {
+		return nil, err
+	}
+	err = p.consume(LEFT_BRACE, "Expect '{' before "+kind+" body.")
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	return NewFunction(name, parameters, body), nil
+}
 func (p *Parser) statement() (Stmt, error) {
+	if p.match(BREAK) {
+		return p.breakStatement()
+	}
+	if p.match(CONTINUE) {
+		return p.continueStatement()
+	}
 	if p.match(FOR) {
 		return p.forStatement()
 	}
@@ -77,6 +179,9 @@ func (p *Parser) statement() (Stmt, error) {
 	if p.match(PRINT) {
 		return p.printStatement()
 	}
+	if p.match(RETURN) {
+		return p.returnStatement()
+	}
 	if p.match(LEFT_BRACE) {
 		return p.blockStatement()
 	}
@@ -84,6 +189,7 @@ func (p *Parser) statement() (Stmt, error) {
 }
 
 func (p *Parser) forStatement() (Stmt, error) {
+	keyword := p.previous()
 	err := p.consume(LEFT_PAREN, "Expect '(' after 'for'.")
 	if err != nil {
 		return nil, err
@@ -128,16 +234,12 @@ func (p *Parser) forStatement() (Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
-	if increment != nil {
-		var statements []Stmt
-		statements = append(statements, body)
-		statements = append(statements, NewExpression(increment))
-		body = NewBlock(statements)
-	}
 	if condition == nil {
-		condition = NewLiteral(true)
+		condition = NewLiteral(keyword, BoolValue(true))
 	}
-	body = NewWhile(condition, body)
+	// The increment stays on the loop rather than in the body so that
+	// 'continue' still runs it.
+	body = NewWhile(keyword, condition, body, increment)
 	if initializer != nil {
 		var statements []Stmt
 		statements = append(statements, initializer)
@@ -160,7 +262,9 @@ func (p *Parser) ifStatement() (Stmt, error) {
 		return nil, err
 	}
 	err = p.consume(RIGHT_PAREN, "Expect ')' after if condition.")
-
+	if err != nil {
+		return nil, err
+	}
 	thenBranch, err = p.statement()
 	if err != nil {
 		return nil, err
@@ -175,11 +279,15 @@ func (p *Parser) ifStatement() (Stmt, error) {
 	return NewIf(condition, thenBranch, elseBranch), nil
 }
 func (p *Parser) whileStatement() (Stmt, error) {
+	keyword := p.previous()
 	err := p.consume(LEFT_PAREN, "Expect '(' after 'while'.")
 	if err != nil {
 		return nil, err
 	}
 	condition, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
 	err = p.consume(RIGHT_PAREN, "Expect ')' after while condition.")
 	if err != nil {
 		return nil, err
@@ -189,22 +297,44 @@ func (p *Parser) whileStatement() (Stmt, error) {
 		return nil, err
 	}
 
-	return NewWhile(condition, body), nil
+	return NewWhile(keyword, condition, body, nil), nil
+}
+func (p *Parser) breakStatement() (Stmt, error) {
+	keyword := p.previous()
+	err := p.consume(SEMICOLON, "Expect ';' after 'break'.")
+	if err != nil {
+		return nil, err
+	}
+	return NewBreak(keyword), nil
+}
+func (p *Parser) continueStatement() (Stmt, error) {
+	keyword := p.previous()
+	err := p.consume(SEMICOLON, "Expect ';' after 'continue'.")
+	if err != nil {
+		return nil, err
+	}
+	return NewContinue(keyword), nil
 }
 func (p *Parser) blockStatement() (Stmt, error) {
+	statements, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	return NewBlock(statements), nil
+}
+func (p *Parser) block() ([]Stmt, error) {
 	var statements []Stmt
 	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
-		stmt, err := p.declaration()
-		if err != nil {
-			return nil, err
+		stmt := p.declaration()
+		if stmt != nil {
+			statements = append(statements, stmt)
 		}
-		statements = append(statements, stmt)
 	}
 	err := p.consume(RIGHT_BRACE, "Expect '}' after block.")
 	if err != nil {
 		return nil, err
 	}
-	return NewBlock(statements), nil
+	return statements, nil
 }
 func (p *Parser) printStatement() (Stmt, error) {
 	var value Expr
@@ -219,6 +349,22 @@ func (p *Parser) printStatement() (Stmt, error) {
 	}
 	return NewPrint(value), nil
 }
+func (p *Parser) returnStatement() (Stmt, error) {
+	keyword := p.previous()
+	var value Expr
+	var err error
+	if !p.check(SEMICOLON) {
+		value, err = p.expression()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = p.consume(SEMICOLON, "Expect ';' after return value.")
+	if err != nil {
+		return nil, err
+	}
+	return NewReturn(keyword, value), nil
+}
 func (p *Parser) expressionStatement() (Stmt, error) {
 	var value Expr
 	var err error
@@ -226,6 +372,9 @@ func (p *Parser) expressionStatement() (Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
+	if p.prompt && p.isAtEnd() {
+		return NewExpression(value), nil
+	}
 	err = p.consume(SEMICOLON, "Expect ';' after expression.")
 	if err != nil {
 		return nil, err
@@ -249,12 +398,16 @@ func (p *Parser) assignment() (Expr, error) {
 		if err != nil {
 			return nil, err
 		}
-		variable, isVariable := expr.(Variable)
+		variable, isVariable := expr.(*Variable)
 		if isVariable {
 			name := variable.name
 			return NewAssign(name, value), nil
 		}
-		TokenError(equals, "Invalid Assignment target.")
+		get, isGet := expr.(*Get)
+		if isGet {
+			return NewSet(get.object, get.name, value), nil
+		}
+		p.error(equals, "Invalid Assignment target.")
 	}
 	return expr, nil
 }
@@ -366,26 +519,83 @@ func (p *Parser) factor() (Expr, error) {
 }
 
 func (p *Parser) unary() (Expr, error) {
-	for p.match(BANG, MINUS) {
+	if p.match(BANG, MINUS) {
 		operator := p.previous()
-		left, err := p.primary()
-		return NewUnary(operator, left), err
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return NewUnary(operator, right), nil
+	}
+	return p.call()
+}
+
+func (p *Parser) call() (Expr, error) {
+	expr, err := p.primary()
+	if err != nil {
+		return nil, err
 	}
-	return p.primary()
+	for {
+		if p.match(LEFT_PAREN) {
+			expr, err = p.finishCall(expr)
+			if err != nil {
+				return nil, err
+			}
+		} else if p.match(DOT) {
+			err = p.consume(IDENTIFIER, "Expect property name after '.'.")
+			if err != nil {
+				return nil, err
+			}
+			expr = NewGet(expr, p.previous())
+		} else {
+			break
+		}
+	}
+	return expr, nil
+}
+
+func (p *Parser) finishCall(callee Expr) (Expr, error) {
+	var arguments []Expr
+	if !p.check(RIGHT_PAREN) {
+		for {
+			if len(arguments) >= 255 {
+				p.error(p.peek(), "Can't have more than 255 arguments.")
+			}
+			argument, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			arguments = append(arguments, argument)
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	err := p.consume(RIGHT_PAREN, "Expect ')' after arguments.")
+	if err != nil {
+		return nil, err
+	}
+	return NewCall(callee, p.previous(), arguments), nil
 }
 func (p *Parser) primary() (Expr, error) {
 
 	if p.match(FALSE) {
-		return NewLiteral(false), nil
+		return NewLiteral(p.previous(), BoolValue(false)), nil
 	}
 	if p.match(TRUE) {
-		return NewLiteral(true), nil
+		return NewLiteral(p.previous(), BoolValue(true)), nil
 	}
 	if p.match(NIL) {
-		return NewLiteral(nil), nil
+		return NewLiteral(p.previous(), NilValue()), nil
 	}
 	if p.match(NUMBER, STRING) {
-		return NewLiteral(p.previous().Literal), nil
+		return NewLiteral(p.previous(), p.previous().Literal), nil
+	}
+	if p.match(INTERPOLATION) {
+		return p.interpolation()
+	}
+	if p.match(THIS) {
+		return NewThis(p.previous()), nil
 	}
 	if p.match(IDENTIFIER) {
 		return NewVariable(p.previous()), nil
@@ -401,6 +611,38 @@ func (p *Parser) primary() (Expr, error) {
 	return nil, p.error(p.peek(), "Expect expression")
 }
 
+// interpolation parses the rest of a string literal holding "${...}" once
+// its first INTERPOLATION token has been matched. The parts alternate
+// between the literal text and the interpolated expressions.
+func (p *Parser) interpolation() (Expr, error) {
+	start := p.previous()
+	parts := []Expr{NewLiteral(start, start.Literal)}
+	reported := false
+	for {
+		// Reported once, on the first expression past the limit, rather
+		// than for every expression after it.
+		if len(parts) >= 255 && !reported {
+			p.error(p.peek(), "Can't have more than 255 parts in a string interpolation.")
+			reported = true
+		}
+		expr, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, expr)
+		if p.match(INTERPOLATION) {
+			parts = append(parts, NewLiteral(p.previous(), p.previous().Literal))
+			continue
+		}
+		err = p.consume(INTERPOLATION_END, "Expect '}' after interpolated expression.")
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, NewLiteral(p.previous(), p.previous().Literal))
+		return NewInterpolation(start, parts), nil
+	}
+}
+
 func (p *Parser) match(types ...TokenType) bool {
 	for _, token_type := range types {
 		if p.check(token_type) {
@@ -443,9 +685,22 @@ func (p *Parser) consume(token_type TokenType, message string) error {
 }
 
 func (p *Parser) error(token Token, message string) error {
-	TokenError(token, message)
+	p.reporter.Report(newTokenDiagnostic(PARSE_ERROR, token, message))
 	return errors.New("ParseError")
 }
 
+// synchronize discards tokens until it reaches what is probably the start of
+// the next statement: just past a semicolon or at a keyword that begins one.
 func (p *Parser) synchronize() {
+	p.advance()
+	for !p.isAtEnd() {
+		if p.previous().Type == SEMICOLON {
+			return
+		}
+		switch p.peek().Type {
+		case CLASS, FUN, VAR, FOR, IF, WHILE, PRINT, RETURN:
+			return
+		}
+		p.advance()
+	}
 }
@@ -0,0 +1,64 @@
+package lox
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInterpolationPartLimit(t *testing.T) {
+	tests := []struct {
+		expressions int
+		errors      int
+	}{
+		// The text around n expressions makes 2n+1 parts.
+		{127, 0},
+		{128, 1},
+		{300, 1},
+	}
+	for _, test := range tests {
+		source := "print \"" + strings.Repeat("${1}", test.expressions) + "\";"
+		collector := NewDiagnosticCollector("")
+		scanner := NewScanner(source, collector)
+		parser := NewParser(scanner.ScanTokens(), collector)
+		parser.Parse()
+		if len(collector.Diagnostics) != test.errors {
+			t.Errorf("%d expressions: got %v, want %d errors", test.expressions, collector.Diagnostics, test.errors)
+		}
+		for _, diagnostic := range collector.Diagnostics {
+			if diagnostic.Message != "Can't have more than 255 parts in a string interpolation." {
+				t.Errorf("%d expressions: unexpected %v", test.expressions, diagnostic)
+			}
+		}
+	}
+}
+
+// TestParserRecoversFromErrors checks each unrelated syntax error is
+// reported once, on its own line, and the statements around them still
+// parse.
+func TestParserRecoversFromErrors(t *testing.T) {
+	source := "var = 1;\n" +
+		"print 2 +;\n" +
+		"var ok = 3;\n" +
+		"print (4;\n" +
+		"print ok;\n"
+	collector := NewDiagnosticCollector("")
+	scanner := NewScanner(source, collector)
+	parser := NewParser(scanner.ScanTokens(), collector)
+	statements := parser.Parse()
+	var got []string
+	for _, diagnostic := range collector.Diagnostics {
+		got = append(got, diagnostic.String())
+	}
+	want := []string{
+		"[line 1] Error at '=': Expect variable name",
+		"[line 2] Error at ';': Expect expression",
+		"[line 4] Error at ';': Expect ')' after expression.",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got diagnostics\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(statements) != 2 {
+		t.Errorf("got %d statements, want the 2 without errors", len(statements))
+	}
+}
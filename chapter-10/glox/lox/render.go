@@ -0,0 +1,229 @@
+package lox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// RenderText writes diagnostics in jlox's plain "[line N] Error ..." format.
+func RenderText(w io.Writer, diagnostics []Diagnostic) error {
+	for _, diagnostic := range diagnostics {
+		_, err := fmt.Fprintln(w, diagnostic)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenderTextWithSource is RenderText followed, for each diagnostic, by the
+// offending line of source with the reported range underlined:
+//
+//	[line 2] Error at 'b': Can't read local variable in its own initializer.
+//	    2 | { var b = b; }
+//	      |           ^
+func RenderTextWithSource(w io.Writer, diagnostics []Diagnostic, source string) error {
+	for _, diagnostic := range diagnostics {
+		_, err := fmt.Fprintln(w, diagnostic)
+		if err != nil {
+			return err
+		}
+		snippet, exists := sourceSnippet(diagnostic, source)
+		if !exists {
+			continue
+		}
+		_, err = io.WriteString(w, snippet)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sourceSnippet renders the line holding the start of diagnostic's span and a
+// caret underline beneath the part of the span on that line.
+func sourceSnippet(diagnostic Diagnostic, source string) (string, bool) {
+	start := diagnostic.Span.Start
+	if diagnostic.Column == 0 || start > len(source) {
+		return "", false
+	}
+	lineStart := strings.LastIndexByte(source[:start], '\n') + 1
+	lineEnd := len(source)
+	if newline := strings.IndexByte(source[start:], '\n'); newline >= 0 {
+		lineEnd = start + newline
+	}
+	end := diagnostic.Span.End
+	if end > lineEnd {
+		end = lineEnd
+	}
+	if end < start {
+		end = start
+	}
+
+	// Keep tabs in the indent so the carets line up with the source.
+	var indent strings.Builder
+	for _, c := range source[lineStart:start] {
+		if c == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+	width := utf8.RuneCountInString(source[start:end])
+	if width == 0 {
+		width = 1
+	}
+
+	gutter := fmt.Sprintf("%5d | ", diagnostic.Line)
+	var builder strings.Builder
+	builder.WriteString(gutter)
+	builder.WriteString(strings.TrimRight(source[lineStart:lineEnd], "\r"))
+	builder.WriteString("\n")
+	builder.WriteString(strings.Repeat(" ", len(gutter)-2))
+	builder.WriteString("| ")
+	builder.WriteString(indent.String())
+	builder.WriteString(strings.Repeat("^", width))
+	builder.WriteString("\n")
+	return builder.String(), true
+}
+
+type jsonSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type jsonDiagnostic struct {
+	Severity string   `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column,omitempty"`
+	Span     jsonSpan `json:"span"`
+}
+
+// RenderJSON writes diagnostics as a JSON array, one object per diagnostic.
+func RenderJSON(w io.Writer, diagnostics []Diagnostic) error {
+	out := make([]jsonDiagnostic, 0, len(diagnostics))
+	for _, diagnostic := range diagnostics {
+		out = append(out, jsonDiagnostic{
+			Severity: diagnostic.Severity.String(),
+			Code:     diagnostic.Code,
+			Message:  diagnostic.Message,
+			File:     diagnostic.File,
+			Line:     diagnostic.Line,
+			Column:   diagnostic.Column,
+			Span:     jsonSpan{Start: diagnostic.Span.Start, End: diagnostic.Span.End},
+		})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// The subset of SARIF 2.1.0 that CI code-scanning integrations read.
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifRegion leaves out the offsets of a diagnostic with no span, rather
+// than claiming the start of the file.
+type sarifRegion struct {
+	StartLine   int  `json:"startLine"`
+	StartColumn int  `json:"startColumn,omitempty"`
+	CharOffset  *int `json:"charOffset,omitempty"`
+	CharLength  *int `json:"charLength,omitempty"`
+}
+
+// RenderSARIF writes diagnostics as a SARIF 2.1.0 log with a single run.
+// SARIF counts offsets in characters where spans count bytes, so source is
+// needed to convert them. Columns are counted in code points, as the run's
+// columnKind says.
+func RenderSARIF(w io.Writer, diagnostics []Diagnostic, source string) error {
+	rules := []sarifRule{}
+	seen := make(map[string]bool)
+	results := make([]sarifResult, 0, len(diagnostics))
+	for _, diagnostic := range diagnostics {
+		if !seen[diagnostic.Code] {
+			seen[diagnostic.Code] = true
+			rules = append(rules, sarifRule{ID: diagnostic.Code})
+		}
+		region := sarifRegion{StartLine: diagnostic.Line, StartColumn: diagnostic.Column}
+		if diagnostic.Column != 0 && diagnostic.Span.End <= len(source) {
+			offset := utf8.RuneCountInString(source[:diagnostic.Span.Start])
+			length := utf8.RuneCountInString(source[diagnostic.Span.Start:diagnostic.Span.End])
+			region.CharOffset, region.CharLength = &offset, &length
+		}
+		results = append(results, sarifResult{
+			RuleID:  diagnostic.Code,
+			Level:   diagnostic.Severity.String(),
+			Message: sarifMessage{Text: diagnostic.Message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: diagnostic.File},
+					Region:           region,
+				},
+			}},
+		})
+	}
+	log := sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool:       sarifTool{Driver: sarifDriver{Name: "glox", Rules: rules}},
+			ColumnKind: "unicodeCodePoints",
+			Results:    results,
+		}},
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
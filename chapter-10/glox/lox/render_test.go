@@ -0,0 +1,245 @@
+package lox
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// renderSource is the file renderDiagnostics report on.
+const renderSource = ")\nprint x;\n@"
+
+// renderDiagnostics covers a diagnostic at the very start of a file, one
+// further in, and one with no known column or span.
+var renderDiagnostics = []Diagnostic{
+	{Severity: SEVERITY_ERROR, Code: PARSE_ERROR, Message: "Expect expression.", File: "main.lox", Line: 1, Column: 1, Span: Span{Start: 0, End: 1}, Where: " at ')'"},
+	{Severity: SEVERITY_ERROR, Code: RUNTIME_ERROR, Message: "Undefined variable 'x'.", File: "main.lox", Line: 2, Column: 7, Span: Span{Start: 8, End: 9}},
+	{Severity: SEVERITY_WARNING, Code: SCAN_ERROR, Message: "Unexpected character.", File: "main.lox", Line: 3},
+}
+
+func TestRenderJSON(t *testing.T) {
+	var output bytes.Buffer
+	if err := RenderJSON(&output, renderDiagnostics); err != nil {
+		t.Fatal(err)
+	}
+	want := `[
+  {
+    "severity": "error",
+    "code": "parse-error",
+    "message": "Expect expression.",
+    "file": "main.lox",
+    "line": 1,
+    "column": 1,
+    "span": {
+      "start": 0,
+      "end": 1
+    }
+  },
+  {
+    "severity": "error",
+    "code": "runtime-error",
+    "message": "Undefined variable 'x'.",
+    "file": "main.lox",
+    "line": 2,
+    "column": 7,
+    "span": {
+      "start": 8,
+      "end": 9
+    }
+  },
+  {
+    "severity": "warning",
+    "code": "scan-error",
+    "message": "Unexpected character.",
+    "file": "main.lox",
+    "line": 3,
+    "span": {
+      "start": 0,
+      "end": 0
+    }
+  }
+]
+`
+	if output.String() != want {
+		t.Errorf("JSON mismatch\n--- got ---\n%s--- want ---\n%s", output.String(), want)
+	}
+}
+
+func TestRenderJSONWithoutDiagnostics(t *testing.T) {
+	var output bytes.Buffer
+	if err := RenderJSON(&output, nil); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "[]\n" {
+		t.Errorf("got %q, want an empty array", output.String())
+	}
+}
+
+func TestRenderSARIF(t *testing.T) {
+	var output bytes.Buffer
+	if err := RenderSARIF(&output, renderDiagnostics, renderSource); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "version": "2.1.0",
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "glox",
+          "rules": [
+            {
+              "id": "parse-error"
+            },
+            {
+              "id": "runtime-error"
+            },
+            {
+              "id": "scan-error"
+            }
+          ]
+        }
+      },
+      "columnKind": "unicodeCodePoints",
+      "results": [
+        {
+          "ruleId": "parse-error",
+          "level": "error",
+          "message": {
+            "text": "Expect expression."
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "main.lox"
+                },
+                "region": {
+                  "startLine": 1,
+                  "startColumn": 1,
+                  "charOffset": 0,
+                  "charLength": 1
+                }
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "runtime-error",
+          "level": "error",
+          "message": {
+            "text": "Undefined variable 'x'."
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "main.lox"
+                },
+                "region": {
+                  "startLine": 2,
+                  "startColumn": 7,
+                  "charOffset": 8,
+                  "charLength": 1
+                }
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "scan-error",
+          "level": "warning",
+          "message": {
+            "text": "Unexpected character."
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "main.lox"
+                },
+                "region": {
+                  "startLine": 3
+                }
+              }
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}
+`
+	if output.String() != want {
+		t.Errorf("SARIF mismatch\n--- got ---\n%s--- want ---\n%s", output.String(), want)
+	}
+}
+
+// TestRenderSARIFCountsCharacters checks offsets after multi-byte text are
+// counted in characters, as SARIF defines them, not in bytes.
+func TestRenderSARIFCountsCharacters(t *testing.T) {
+	source := "var π = 3;\nπ = \"é\" + ;"
+	collector := NewDiagnosticCollector("")
+	analyze(source, NewInterpreter(), collector)
+	var output bytes.Buffer
+	if err := RenderSARIF(&output, collector.Diagnostics, source); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(output.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	region := log.Runs[0].Results[0].Locations[0].PhysicalLocation.Region
+	if region.CharOffset == nil || *region.CharOffset != 21 || region.CharLength == nil || *region.CharLength != 1 {
+		t.Errorf("got region %+v, want charOffset 21 and charLength 1", region)
+	}
+}
+
+func TestRenderTextWithSource(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "resolve error",
+			source: "{ var b = b; }",
+			want: "[line 1] Error at 'b': Can't read local variable in its own initializer.\n" +
+				"    1 | { var b = b; }\n" +
+				"      |           ^\n",
+		},
+		{
+			name:   "tab indentation",
+			source: "fun f() {\n\tif (true) {\n\t\treturn 1 +;\n\t}\n}",
+			want: "[line 3] Error at ';': Expect expression\n" +
+				"    3 | \t\treturn 1 +;\n" +
+				"      | \t\t          ^\n",
+		},
+		{
+			name:   "span past the end of its line",
+			source: "print 1; /* never\nclosed",
+			want: "[line 1] Error: Unterminated comment.\n" +
+				"    1 | print 1; /* never\n" +
+				"      |          ^^^^^^^^\n",
+		},
+		{
+			name:   "multi-byte characters",
+			source: "var π = 3;\nπ = \"é\" + ;",
+			want: "[line 2] Error at ';': Expect expression\n" +
+				"    2 | π = \"é\" + ;\n" +
+				"      |           ^\n",
+		},
+	}
+	for _, test := range tests {
+		collector := NewDiagnosticCollector("")
+		analyze(test.source, NewInterpreter(), collector)
+		var output bytes.Buffer
+		if err := RenderTextWithSource(&output, collector.Diagnostics, test.source); err != nil {
+			t.Fatal(err)
+		}
+		if output.String() != test.want {
+			t.Errorf("%s mismatch\n--- got ---\n%s--- want ---\n%s", test.name, output.String(), test.want)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package lox
+
+// local tracks a declared name. It is not defined until its initializer
+// has been resolved, which lets us catch reads from inside the initializer.
+type local struct {
+	slot    int
+	defined bool
+}
+
+// scope maps every name declared in a local block to its slot in the
+// matching runtime Environment.
+type scope struct {
+	locals map[string]*local
+	count  int
+}
+
+func newScope() *scope {
+	return &scope{
+		locals: make(map[string]*local),
+	}
+}
+
+type FunctionType int
+
+const (
+	NONE FunctionType = iota
+	FUNCTION
+	INITIALIZER
+	METHOD
+)
+
+type ClassType int
+
+const (
+	NO_CLASS ClassType = iota
+	IN_CLASS
+)
+
+// Resolver walks the syntax tree once before it is interpreted and binds
+// every local variable access to the (depth, slot) pair it refers to.
+// Anything left unresolved is treated as a global. With a nil interpreter
+// it only reports scope errors, which is all the VM needs from it.
+type Resolver struct {
+	interpreter     *Interpreter
+	scopes          []*scope
+	currentFunction FunctionType
+	currentClass    ClassType
+	loopDepth       int
+	reporter        ErrorReporter
+}
+
+func NewResolver(interpreter *Interpreter, reporter ErrorReporter) Resolver {
+	return Resolver{
+		interpreter:     interpreter,
+		currentFunction: NONE,
+		currentClass:    NO_CLASS,
+		reporter:        reporter,
+	}
+}
+
+func (r *Resolver) Resolve(statements []Stmt) {
+	for _, statement := range statements {
+		r.resolveStmt(statement)
+	}
+}
+
+func (r *Resolver) error(token Token, message string) {
+	r.reporter.Report(newTokenDiagnostic(RESOLVE_ERROR, token, message))
+}
+
+func (r *Resolver) VisitBlockStmt(stmt *Block) (interface{}, error) {
+	r.beginScope()
+	r.Resolve(stmt.statements)
+	r.endScope()
+	return nil, nil
+}
+func (r *Resolver) VisitBreakStmt(stmt *Break) (interface{}, error) {
+	if r.loopDepth == 0 {
+		r.error(stmt.keyword, "Can't use 'break' outside of a loop.")
+	}
+	return nil, nil
+}
+func (r *Resolver) VisitClassStmt(stmt *Class) (interface{}, error) {
+	enclosingClass := r.currentClass
+	r.currentClass = IN_CLASS
+	r.declare(stmt.name)
+	r.define(stmt.name)
+
+	// Bound methods close over a scope holding only "this", in slot 0.
+	r.beginScope()
+	r.scopes[len(r.scopes)-1].locals["this"] = &local{slot: 0, defined: true}
+	r.scopes[len(r.scopes)-1].count++
+	for _, method := range stmt.methods {
+		declaration := METHOD
+		if method.name.Lexeme == "init" {
+			declaration = INITIALIZER
+		}
+		r.resolveFunction(method, declaration)
+	}
+	r.endScope()
+
+	r.currentClass = enclosingClass
+	return nil, nil
+}
+func (r *Resolver) VisitContinueStmt(stmt *Continue) (interface{}, error) {
+	if r.loopDepth == 0 {
+		r.error(stmt.keyword, "Can't use 'continue' outside of a loop.")
+	}
+	return nil, nil
+}
+func (r *Resolver) VisitExpressionStmt(stmt *Expression) (interface{}, error) {
+	r.resolveExpr(stmt.expression)
+	return nil, nil
+}
+func (r *Resolver) VisitFunctionStmt(stmt *Function) (interface{}, error) {
+	r.declare(stmt.name)
+	r.define(stmt.name)
+	r.resolveFunction(stmt, FUNCTION)
+	return nil, nil
+}
+func (r *Resolver) VisitIfStmt(stmt *If) (interface{}, error) {
+	r.resolveExpr(stmt.condition)
+	r.resolveStmt(stmt.thenBranch)
+	if stmt.elseBranch != nil {
+		r.resolveStmt(stmt.elseBranch)
+	}
+	return nil, nil
+}
+func (r *Resolver) VisitPrintStmt(stmt *Print) (interface{}, error) {
+	r.resolveExpr(stmt.expression)
+	return nil, nil
+}
+func (r *Resolver) VisitReturnStmt(stmt *Return) (interface{}, error) {
+	if r.currentFunction == NONE {
+		r.error(stmt.keyword, "Can't return from top-level code.")
+	}
+	if stmt.value != nil {
+		if r.currentFunction == INITIALIZER {
+			r.error(stmt.keyword, "Can't return a value from an initializer.")
+		}
+		r.resolveExpr(stmt.value)
+	}
+	return nil, nil
+}
+func (r *Resolver) VisitVarStmt(stmt *Var) (interface{}, error) {
+	r.declare(stmt.name)
+	if stmt.initializer != nil {
+		r.resolveExpr(stmt.initializer)
+	}
+	r.define(stmt.name)
+	return nil, nil
+}
+func (r *Resolver) VisitWhileStmt(stmt *While) (interface{}, error) {
+	r.resolveExpr(stmt.condition)
+	r.loopDepth++
+	r.resolveStmt(stmt.body)
+	r.loopDepth--
+	if stmt.increment != nil {
+		r.resolveExpr(stmt.increment)
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitAssignExpr(expr *Assign) (interface{}, error) {
+	r.resolveExpr(expr.value)
+	r.resolveLocal(expr, expr.name)
+	return nil, nil
+}
+func (r *Resolver) VisitBinaryExpr(expr *Binary) (interface{}, error) {
+	r.resolveExpr(expr.left)
+	r.resolveExpr(expr.right)
+	return nil, nil
+}
+func (r *Resolver) VisitCallExpr(expr *Call) (interface{}, error) {
+	r.resolveExpr(expr.callee)
+	for _, argument := range expr.arguments {
+		r.resolveExpr(argument)
+	}
+	return nil, nil
+}
+func (r *Resolver) VisitGetExpr(expr *Get) (interface{}, error) {
+	r.resolveExpr(expr.object)
+	return nil, nil
+}
+func (r *Resolver) VisitSetExpr(expr *Set) (interface{}, error) {
+	r.resolveExpr(expr.value)
+	r.resolveExpr(expr.object)
+	return nil, nil
+}
+func (r *Resolver) VisitThisExpr(expr *This) (interface{}, error) {
+	if r.currentClass == NO_CLASS {
+		r.error(expr.keyword, "Can't use 'this' outside of a class.")
+		return nil, nil
+	}
+	r.resolveLocal(expr, expr.keyword)
+	return nil, nil
+}
+func (r *Resolver) VisitGroupingExpr(expr *Grouping) (interface{}, error) {
+	r.resolveExpr(expr.expression)
+	return nil, nil
+}
+func (r *Resolver) VisitInterpolationExpr(expr *Interpolation) (interface{}, error) {
+	for _, part := range expr.parts {
+		r.resolveExpr(part)
+	}
+	return nil, nil
+}
+func (r *Resolver) VisitLiteralExpr(expr *Literal) (interface{}, error) {
+	return nil, nil
+}
+func (r *Resolver) VisitLogicalExpr(expr *Logical) (interface{}, error) {
+	r.resolveExpr(expr.left)
+	r.resolveExpr(expr.right)
+	return nil, nil
+}
+func (r *Resolver) VisitUnaryExpr(expr *Unary) (interface{}, error) {
+	r.resolveExpr(expr.right)
+	return nil, nil
+}
+func (r *Resolver) VisitVariableExpr(expr *Variable) (interface{}, error) {
+	if len(r.scopes) > 0 {
+		declared, exists := r.scopes[len(r.scopes)-1].locals[expr.name.Lexeme]
+		if exists && !declared.defined {
+			r.error(expr.name, "Can't read local variable in its own initializer.")
+		}
+	}
+	r.resolveLocal(expr, expr.name)
+	return nil, nil
+}
+
+func (r *Resolver) resolveStmt(stmt Stmt) {
+	stmt.Accept(r)
+}
+
+func (r *Resolver) resolveExpr(expr Expr) {
+	expr.Accept(r)
+}
+
+func (r *Resolver) resolveFunction(function *Function, functionType FunctionType) {
+	enclosingFunction := r.currentFunction
+	r.currentFunction = functionType
+	// A loop around the declaration does not enclose the function body.
+	enclosingLoopDepth := r.loopDepth
+	r.loopDepth = 0
+	r.beginScope()
+	for _, param := range function.params {
+		r.declare(param)
+		r.define(param)
+	}
+	r.Resolve(function.body)
+	r.endScope()
+	r.loopDepth = enclosingLoopDepth
+	r.currentFunction = enclosingFunction
+}
+
+func (r *Resolver) beginScope() {
+	r.scopes = append(r.scopes, newScope())
+}
+
+func (r *Resolver) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+// declare reserves the next slot of the innermost scope for name. The
+// interpreter defines locals in the same order, so the slots line up.
+func (r *Resolver) declare(name Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	scope := r.scopes[len(r.scopes)-1]
+	if _, exists := scope.locals[name.Lexeme]; exists {
+		r.error(name, "Already a variable with this name in this scope.")
+	}
+	scope.locals[name.Lexeme] = &local{slot: scope.count}
+	scope.count++
+}
+
+// define marks name as ready for use once its initializer is resolved.
+func (r *Resolver) define(name Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	r.scopes[len(r.scopes)-1].locals[name.Lexeme].defined = true
+}
+
+func (r *Resolver) resolveLocal(expr Expr, name Token) {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if declared, exists := r.scopes[i].locals[name.Lexeme]; exists {
+			if r.interpreter != nil {
+				r.interpreter.resolve(expr, len(r.scopes)-1-i, declared.slot)
+			}
+			return
+		}
+	}
+}
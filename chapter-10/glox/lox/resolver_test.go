@@ -0,0 +1,130 @@
+package lox
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// resolveTest is a program and the resolver errors it should raise, each
+// in jlox's "[line N] Error at ..." form.
+type resolveTest struct {
+	name   string
+	source string
+	want   []string
+}
+
+func runResolveTests(t *testing.T, tests []resolveTest) {
+	t.Helper()
+	for _, test := range tests {
+		collector := NewDiagnosticCollector("")
+		analyze(test.source, NewInterpreter(), collector)
+		var got []string
+		for _, diagnostic := range collector.Diagnostics {
+			got = append(got, diagnostic.String())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+}
+
+func TestResolveScopes(t *testing.T) {
+	runResolveTests(t, []resolveTest{
+		{
+			name:   "redeclared local",
+			source: "{\n  var a = 1;\n  var a = 2;\n}",
+			want:   []string{"[line 3] Error at 'a': Already a variable with this name in this scope."},
+		},
+		{
+			name:   "redeclared parameter",
+			source: "fun f(a) {\n  var a;\n}",
+			want:   []string{"[line 2] Error at 'a': Already a variable with this name in this scope."},
+		},
+		{
+			name:   "shadowing in an inner scope",
+			source: "{\n  var a = 1;\n  {\n    var a = 2;\n  }\n}",
+		},
+		{
+			name:   "redeclared global",
+			source: "var a = 1;\nvar a = 2;",
+		},
+		{
+			name:   "top-level return",
+			source: "print 1;\nreturn 2;",
+			want:   []string{"[line 2] Error at 'return': Can't return from top-level code."},
+		},
+		{
+			name:   "return in a function",
+			source: "fun f() {\n  return 2;\n}",
+		},
+		{
+			name:   "own initializer",
+			source: "var a = 1;\n{\n  var a = a;\n}",
+			want:   []string{"[line 3] Error at 'a': Can't read local variable in its own initializer."},
+		},
+		{
+			name:   "global own initializer",
+			source: "var a = a;",
+		},
+	})
+}
+
+func TestResolveLoopControl(t *testing.T) {
+	runResolveTests(t, []resolveTest{
+		{
+			name:   "top-level break",
+			source: "print 1;\nbreak;",
+			want:   []string{"[line 2] Error at 'break': Can't use 'break' outside of a loop."},
+		},
+		{
+			name:   "top-level continue",
+			source: "continue;",
+			want:   []string{"[line 1] Error at 'continue': Can't use 'continue' outside of a loop."},
+		},
+		{
+			name:   "inside loops",
+			source: "while (true) {\n  if (false) continue;\n  break;\n}\nfor (var i = 0; i < 1; i = i + 1) {\n  { break; }\n}",
+		},
+		{
+			name:   "function declared in a loop",
+			source: "while (true) {\n  fun f() {\n    break;\n  }\n  fun g() {\n    continue;\n  }\n  break;\n}",
+			want: []string{
+				"[line 3] Error at 'break': Can't use 'break' outside of a loop.",
+				"[line 6] Error at 'continue': Can't use 'continue' outside of a loop.",
+			},
+		},
+		{
+			name:   "loop in a function in a loop",
+			source: "while (true) {\n  fun f() {\n    while (true) break;\n  }\n  break;\n}",
+		},
+	})
+}
+
+func TestResolveClasses(t *testing.T) {
+	runResolveTests(t, []resolveTest{
+		{
+			name:   "top-level this",
+			source: "print 1;\nprint this;",
+			want:   []string{"[line 2] Error at 'this': Can't use 'this' outside of a class."},
+		},
+		{
+			name:   "this in a function",
+			source: "fun f() {\n  return this;\n}",
+			want:   []string{"[line 2] Error at 'this': Can't use 'this' outside of a class."},
+		},
+		{
+			name:   "this in a method",
+			source: "class A {\n  m() {\n    fun inner() { return this; }\n    return this;\n  }\n}",
+		},
+		{
+			name:   "value returned from init",
+			source: "class A {\n  init() {\n    return 1;\n  }\n}",
+			want:   []string{"[line 3] Error at 'return': Can't return a value from an initializer."},
+		},
+		{
+			name:   "bare return in init",
+			source: "class A {\n  init(x) {\n    if (x) return;\n    this.x = x;\n  }\n}",
+		},
+	})
+}
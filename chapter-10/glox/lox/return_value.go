@@ -0,0 +1,11 @@
+package lox
+
+// ReturnValue is passed up through executeBlock as an error so a return
+// statement can unwind to the enclosing LoxFunction.Call.
+type ReturnValue struct {
+	Value Value
+}
+
+func (r ReturnValue) Error() string {
+	return "return outside of function"
+}
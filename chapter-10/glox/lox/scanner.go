@@ -1,8 +1,12 @@
-package main
+package lox
 
 import (
+	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Scanner struct {
@@ -12,33 +16,58 @@ type Scanner struct {
 	current  int
 	line     int
 	keywords map[string]TokenType
+	// lineStart is the offset of the first byte of the current line;
+	// startLine and startLineStart record the same for the current lexeme.
+	lineStart      int
+	startLine      int
+	startLineStart int
+	reporter       ErrorReporter
+	// interpolations holds, for each "${" still open, how many braces
+	// have been opened inside it and not yet closed.
+	interpolations []int
 }
 
-func NewScanner(source string) Scanner {
-	keywords := map[string]TokenType{
-		"and":    AND,
-		"class":  CLASS,
-		"else":   ELSE,
-		"false":  FALSE,
-		"for":    FOR,
-		"fun":    FUN,
-		"if":     IF,
-		"nil":    NIL,
-		"or":     OR,
-		"print":  PRINT,
-		"return": RETURN,
-		"super":  SUPER,
-		"this":   THIS,
-		"true":   TRUE,
-		"var":    VAR,
-		"while":  WHILE,
+// keywords maps each reserved word to its token type.
+var keywords = map[string]TokenType{
+	"and":      AND,
+	"break":    BREAK,
+	"class":    CLASS,
+	"continue": CONTINUE,
+	"else":     ELSE,
+	"false":    FALSE,
+	"for":      FOR,
+	"fun":      FUN,
+	"if":       IF,
+	"nil":      NIL,
+	"or":       OR,
+	"print":    PRINT,
+	"return":   RETURN,
+	"super":    SUPER,
+	"this":     THIS,
+	"true":     TRUE,
+	"var":      VAR,
+	"while":    WHILE,
+}
+
+// Keywords returns the reserved words, sorted.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
 	}
+	sort.Strings(words)
+	return words
+}
+
+func NewScanner(source string, reporter ErrorReporter) Scanner {
 	return Scanner{
-		source:   source,
-		start:    0,
-		current:  0,
-		line:     1,
-		keywords: keywords,
+		source:    source,
+		start:     0,
+		current:   0,
+		line:      1,
+		startLine: 1,
+		keywords:  keywords,
+		reporter:  reporter,
 	}
 }
 
@@ -47,12 +76,57 @@ func (s *Scanner) ScanTokens() []Token {
 	for !s.isAtEnd() {
 		// We are at the beginning of the next lexeme.
 		s.start = s.current
+		s.startLine = s.line
+		s.startLineStart = s.lineStart
 		s.scanToken()
 	}
-	s.tokens = append(s.tokens, NewToken(EOF, "", nil, s.line))
+	s.start = s.current
+	s.startLine = s.line
+	s.startLineStart = s.lineStart
+	s.tokens = append(s.tokens, s.makeToken(EOF, "", NilValue()))
 	return s.tokens
 }
 
+// error reports message against the lexeme currently being scanned.
+func (s *Scanner) error(message string) {
+	s.errorAt(s.startLine, s.startLineStart, s.start, message)
+}
+
+// errorAt reports message against the source from start, which is on line,
+// up to the current character.
+func (s *Scanner) errorAt(line int, lineStart int, start int, message string) {
+	diagnostic := newDiagnostic(SCAN_ERROR, line, message)
+	diagnostic.Column = s.column(lineStart, start)
+	diagnostic.Span = Span{Start: start, End: s.current}
+	s.reporter.Report(diagnostic)
+}
+
+// makeToken builds a token spanning the current lexeme.
+func (s *Scanner) makeToken(tokenType TokenType, lexeme string, literal Value) Token {
+	return Token{
+		Type:      tokenType,
+		Lexeme:    lexeme,
+		Literal:   literal,
+		Line:      s.startLine,
+		Column:    s.column(s.startLineStart, s.start),
+		EndLine:   s.line,
+		EndColumn: s.column(s.lineStart, s.current),
+		Offset:    s.start,
+		EndOffset: s.current,
+	}
+}
+
+// column converts a byte offset into a 1-based column counted in runes from
+// the start of its line.
+func (s *Scanner) column(lineStart int, offset int) int {
+	return utf8.RuneCountInString(s.source[lineStart:offset]) + 1
+}
+
+func (s *Scanner) newline() {
+	s.line++
+	s.lineStart = s.current
+}
+
 func (s Scanner) isAtEnd() bool {
 	return s.current >= len(s.source)
 }
@@ -67,9 +141,21 @@ func (s *Scanner) scanToken() {
 		s.addToken(RIGHT_PAREN)
 		break
 	case '{':
+		if len(s.interpolations) > 0 {
+			s.interpolations[len(s.interpolations)-1]++
+		}
 		s.addToken(LEFT_BRACE)
 		break
 	case '}':
+		if depth := len(s.interpolations) - 1; depth >= 0 {
+			if s.interpolations[depth] == 0 {
+				// The brace closes an interpolation, so the string goes on.
+				s.interpolations = s.interpolations[:depth]
+				s.string(INTERPOLATION_END)
+				break
+			}
+			s.interpolations[depth]--
+		}
 		s.addToken(RIGHT_BRACE)
 		break
 	case ',':
@@ -116,10 +202,9 @@ func (s *Scanner) scanToken() {
 		}
 	case '/':
 		if s.match('/') {
-			// A comment goes until the end of the line.
-			for s.peek() != '\n' && !s.isAtEnd() {
-				s.advance()
-			}
+			s.lineComment()
+		} else if s.match('*') {
+			s.blockComment()
 		} else {
 			s.addToken(SLASH)
 		}
@@ -131,18 +216,28 @@ func (s *Scanner) scanToken() {
 		break
 
 	case '\n':
-		s.line++
+		s.newline()
 		break
 	case '"':
-		s.string()
+		s.string(STRING)
+		break
+	case '`':
+		s.rawString()
 		break
 	default:
 		if s.isDigit(c) {
 			s.number()
-		} else if s.isAlpha(c) {
+		} else if r, size := utf8.DecodeRuneInString(s.source[s.start:]); r >= utf8.RuneSelf && unicode.IsDigit(r) {
+			s.current = s.start + size
+			s.error(fmt.Sprintf("Unexpected digit '%c'; numbers are written with 0-9.", r))
+			// Like a malformed number, it still stands in for one.
+			s.addToken(NUMBER, NumberValue(0))
+		} else if isIdentifierStart(r) {
+			s.current = s.start + size
 			s.identifier()
 		} else {
-			Error(s.line, "Unexpected character.")
+			s.current = s.start + size
+			s.error("Unexpected character.")
 		}
 		break
 	}
@@ -153,13 +248,13 @@ func (s *Scanner) advance() rune {
 	return rune(s.source[s.current-1])
 }
 
-func (s *Scanner) addToken(tokenType TokenType, literals ...interface{}) {
+func (s *Scanner) addToken(tokenType TokenType, literals ...Value) {
 	text := s.source[s.start:s.current]
-	var literal interface{}
+	var literal Value
 	if len(literals) > 0 {
 		literal = literals[0]
 	}
-	s.tokens = append(s.tokens, NewToken(tokenType, text, literal, s.line))
+	s.tokens = append(s.tokens, s.makeToken(tokenType, text, literal))
 
 }
 
@@ -189,52 +284,252 @@ func (s Scanner) peekNext() rune {
 	return rune(s.source[s.current+1])
 }
 
-func (s *Scanner) string() {
+// lineComment scans the rest of a comment that goes until the end of the
+// line. One starting with exactly three slashes is a doc comment and
+// becomes a DOC_COMMENT token; any other is thrown away.
+func (s *Scanner) lineComment() {
+	doc := s.match('/') && s.peek() != '/'
+	for s.peek() != '\n' && !s.isAtEnd() {
+		s.advance()
+	}
+	if doc {
+		text := strings.TrimSuffix(s.source[s.start+3:s.current], "\r")
+		s.addToken(DOC_COMMENT, StringValue(strings.TrimPrefix(text, " ")))
+	}
+}
+
+// blockComment scans the rest of a /* */ comment, which may span lines and
+// contain other block comments, each needing its own */.
+func (s *Scanner) blockComment() {
+	depth := 1
+	for depth > 0 && !s.isAtEnd() {
+		c := s.advance()
+		switch {
+		case c == '\n':
+			s.newline()
+		case c == '/' && s.match('*'):
+			depth++
+		case c == '*' && s.match('/'):
+			depth--
+		}
+	}
+	if depth > 0 {
+		s.error("Unterminated comment.")
+	}
+}
+
+// string scans a string literal, or the rest of one after an
+// interpolation, decoding its escape sequences. An invalid escape is
+// reported and left out, and scanning carries on to the closing quote so
+// the rest of the string is not mistaken for code. A "${" ends the token
+// early as an INTERPOLATION, leaving the expression inside to be scanned
+// as code; otherwise the token is of tokenType.
+func (s *Scanner) string(tokenType TokenType) {
+	var value strings.Builder
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' {
-			s.line++
+		c := s.advance()
+		switch {
+		case c == '\n':
+			s.newline()
+			value.WriteByte('\n')
+		case c == '\\':
+			s.escape(&value)
+		case c == '$' && s.match('{'):
+			s.interpolations = append(s.interpolations, 0)
+			s.addToken(INTERPOLATION, StringValue(value.String()))
+			return
+		default:
+			value.WriteByte(byte(c))
 		}
-		s.advance()
 	}
 	if s.isAtEnd() {
-		Error(s.line, "Unterminated string.")
+		s.error("Unterminated string.")
 		return
 	}
 	// The closing "
 	s.advance()
+	s.addToken(tokenType, StringValue(value.String()))
+}
 
-	// Trim the surrounding quotes
-	start := s.start + 1
-	end := s.current - 1
-	value := s.source[start:end]
-	s.addToken(STRING, value)
+// escape decodes the escape sequence after a backslash into value.
+func (s *Scanner) escape(value *strings.Builder) {
+	start := s.current - 1
+	if s.isAtEnd() {
+		return
+	}
+	c, size := utf8.DecodeRuneInString(s.source[s.current:])
+	s.current += size
+	switch c {
+	case 'n':
+		value.WriteByte('\n')
+	case 't':
+		value.WriteByte('\t')
+	case 'r':
+		value.WriteByte('\r')
+	case '0':
+		value.WriteByte(0)
+	case '"', '\\', '$':
+		value.WriteRune(c)
+	case 'u':
+		s.unicodeEscape(start, value)
+	case '\n':
+		s.errorAt(s.line, s.lineStart, start, "Expect escape sequence after '\\'.")
+		s.newline()
+	default:
+		s.errorAt(s.line, s.lineStart, start, fmt.Sprintf("Invalid escape sequence '\\%c'.", c))
+	}
 }
 
+// unicodeEscape decodes the code point in a \u{...} escape starting at start
+// into value.
+func (s *Scanner) unicodeEscape(start int, value *strings.Builder) {
+	if !s.match('{') {
+		s.errorAt(s.line, s.lineStart, start, "Expect '{' after '\\u'.")
+		return
+	}
+	digits := s.current
+	for isHexDigit(s.peek()) {
+		s.advance()
+	}
+	hex := s.source[digits:s.current]
+	if !s.match('}') {
+		s.errorAt(s.line, s.lineStart, start, "Expect '}' after Unicode escape.")
+		return
+	}
+	codePoint, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) > 6 || !utf8.ValidRune(rune(codePoint)) {
+		s.errorAt(s.line, s.lineStart, start, "Invalid Unicode code point '"+hex+"'.")
+		return
+	}
+	value.WriteRune(rune(codePoint))
+}
+
+// rawString scans a string between backticks, which may span lines and
+// holds its characters exactly as written.
+func (s *Scanner) rawString() {
+	for s.peek() != '`' && !s.isAtEnd() {
+		if s.advance() == '\n' {
+			s.newline()
+		}
+	}
+	if s.isAtEnd() {
+		s.error("Unterminated string.")
+		return
+	}
+	// The closing `
+	s.advance()
+	s.addToken(STRING, StringValue(s.source[s.start+1:s.current-1]))
+}
+
+func isBinaryDigit(c rune) bool {
+	return c == '0' || c == '1'
+}
+
+func isHexDigit(c rune) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// isDigit accepts only ASCII digits: other scripts' digits would not parse
+// as a number.
 func (s Scanner) isDigit(c rune) bool {
-	return unicode.IsDigit(c)
+	return c >= '0' && c <= '9'
 }
-func (s Scanner) isAlpha(c rune) bool {
-	return unicode.IsLetter(c) || unicode.IsDigit(c)
+
+// isIdentifierStart reports whether r can begin an identifier: a letter or
+// an underscore. Letters are those of any script, following the XID_Start
+// set of Unicode's identifier syntax (UAX #31), so café and π are names.
+func isIdentifierStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.In(r, unicode.Nl, unicode.Other_ID_Start)
 }
+
+// IsIdentifierPart reports whether r can continue an identifier: anything
+// that can begin one, a digit, or a combining mark or connector following
+// XID_Continue. A leading digit instead begins a number.
+func IsIdentifierPart(r rune) bool {
+	return isIdentifierStart(r) ||
+		unicode.In(r, unicode.Nd, unicode.Mn, unicode.Mc, unicode.Pc, unicode.Other_ID_Continue)
+}
+
+// number scans a number literal: decimal with an optional fraction and
+// exponent, or an integer in hexadecimal after 0x or binary after 0b. Digits
+// may be grouped with single underscores between them. A malformed number
+// is reported but still becomes a token, so the parser does not report it
+// again as a missing expression.
 func (s *Scanner) number() {
-	for s.isDigit(s.peek()) {
+	var wellFormed bool
+	base := 10
+	if s.source[s.start] == '0' && (s.peek() == 'x' || s.peek() == 'X') {
 		s.advance()
-	}
-	if s.peek() == '.' && s.isDigit(s.peekNext()) {
+		base = 16
+		wellFormed = s.digits(isHexDigit)
+	} else if s.source[s.start] == '0' && (s.peek() == 'b' || s.peek() == 'B') {
 		s.advance()
-		for s.isDigit(s.peek()) {
+		base = 2
+		wellFormed = s.digits(isBinaryDigit)
+	} else {
+		// Rescan the first digit, which may be followed by an underscore.
+		s.current = s.start
+		wellFormed = s.digits(s.isDigit)
+		if s.peek() == '.' && s.isDigit(s.peekNext()) {
+			s.advance()
+			wellFormed = s.digits(s.isDigit) && wellFormed
+		}
+		if s.peek() == 'e' || s.peek() == 'E' {
 			s.advance()
+			if s.peek() == '+' || s.peek() == '-' {
+				s.advance()
+			}
+			wellFormed = s.digits(s.isDigit) && wellFormed
 		}
 	}
-	number, err := strconv.ParseFloat(s.source[s.start:s.current], 64)
-	if err == nil {
-		s.addToken(NUMBER, number)
+	// A number running straight into a name, such as 12px or 0b102, is one
+	// malformed number rather than a number and a name.
+	for s.matchIdentifierPart() {
+		wellFormed = false
+	}
+
+	text := s.source[s.start:s.current]
+	if !wellFormed {
+		s.error(fmt.Sprintf("Malformed number '%s'.", text))
+		s.addToken(NUMBER, NumberValue(0))
+		return
+	}
+	digits := strings.ReplaceAll(text, "_", "")
+	var number float64
+	var err error
+	if base == 10 {
+		number, err = strconv.ParseFloat(digits, 64)
+	} else {
+		var integer uint64
+		integer, err = strconv.ParseUint(digits[2:], base, 64)
+		number = float64(integer)
+	}
+	if err != nil {
+		s.error(fmt.Sprintf("Number '%s' is out of range.", text))
+		number = 0
 	}
+	s.addToken(NUMBER, NumberValue(number))
 }
-func (s *Scanner) identifier() {
-	for s.isAlphaNumeric(s.peek()) {
+
+// digits consumes a run of digits accepted by isDigit and the underscores
+// grouping them, and reports whether there was at least one digit and
+// every underscore sat between two.
+func (s *Scanner) digits(isDigit func(rune) bool) bool {
+	wellFormed := isDigit(s.peek())
+	for isDigit(s.peek()) || s.peek() == '_' {
+		if s.peek() == '_' && !isDigit(s.peekNext()) {
+			wellFormed = false
+		}
 		s.advance()
 	}
+	return wellFormed
+}
+
+// identifier scans the rest of an identifier or keyword whose first
+// character has been consumed.
+func (s *Scanner) identifier() {
+	for s.matchIdentifierPart() {
+	}
 	text := s.source[s.start:s.current]
 	token_type, exists := s.keywords[text]
 	if !exists {
@@ -243,6 +538,13 @@ func (s *Scanner) identifier() {
 	s.addToken(token_type)
 }
 
-func (s Scanner) isAlphaNumeric(c rune) bool {
-	return s.isAlpha(c) || s.isDigit(c)
+// matchIdentifierPart consumes the next character, which may take several
+// bytes, if it can continue an identifier.
+func (s *Scanner) matchIdentifierPart() bool {
+	r, size := utf8.DecodeRuneInString(s.source[s.current:])
+	if !IsIdentifierPart(r) {
+		return false
+	}
+	s.current += size
+	return true
 }
@@ -0,0 +1,343 @@
+package lox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScanStringLiterals(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`"plain"`, "plain"},
+		{`"a\nb\tc\rd"`, "a\nb\tc\rd"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"nul\0"`, "nul\x00"},
+		{`"\u{41}\u{e9}\u{1F600}"`, "Aé😀"},
+		{`"cost: \${x}"`, "cost: ${x}"},
+		{`"$x {y}"`, "$x {y}"},
+		{`"é\u{20AC}"`, "é€"},
+		{"\"two\nlines\"", "two\nlines"},
+		{"`raw \\n \"quoted\"`", `raw \n "quoted"`},
+		{"`two\nlines`", "two\nlines"},
+	}
+	for _, test := range tests {
+		collector := NewDiagnosticCollector("")
+		scanner := NewScanner(test.source, collector)
+		tokens := scanner.ScanTokens()
+		if collector.HadError() {
+			t.Errorf("%s: %v", test.source, collector.Diagnostics)
+			continue
+		}
+		if tokens[0].Type != STRING || tokens[0].Literal.AsString() != test.want {
+			t.Errorf("%s scanned to %v %q, want STRING %q", test.source, tokens[0].Type, tokens[0].Literal.AsString(), test.want)
+		}
+		if tokens[0].Lexeme != test.source {
+			t.Errorf("%s has lexeme %q", test.source, tokens[0].Lexeme)
+		}
+	}
+}
+
+func TestScanStringErrors(t *testing.T) {
+	type position struct {
+		Line    int
+		Column  int
+		Message string
+	}
+	tests := []struct {
+		source string
+		want   []position
+	}{
+		{`"bad \q escape"`, []position{{1, 6, `Invalid escape sequence '\q'.`}}},
+		{"\"line one\n  \\x and \\y\"", []position{
+			{2, 3, `Invalid escape sequence '\x'.`},
+			{2, 10, `Invalid escape sequence '\y'.`},
+		}},
+		{`"\u41"`, []position{{1, 2, `Expect '{' after '\u'.`}}},
+		{`"\u{41"`, []position{{1, 2, `Expect '}' after Unicode escape.`}}},
+		{`"\u{}"`, []position{{1, 2, `Invalid Unicode code point ''.`}}},
+		{`"\u{D800}"`, []position{{1, 2, `Invalid Unicode code point 'D800'.`}}},
+		{`"\u{1234567}"`, []position{{1, 2, `Invalid Unicode code point '1234567'.`}}},
+		{"\"end\\\nx\"", []position{{1, 5, `Expect escape sequence after '\'.`}}},
+		{`"open \"`, []position{{1, 1, "Unterminated string."}}},
+		{"`open", []position{{1, 1, "Unterminated string."}}},
+	}
+	for _, test := range tests {
+		collector := NewDiagnosticCollector("")
+		scanner := NewScanner(test.source, collector)
+		scanner.ScanTokens()
+		var got []position
+		for _, diagnostic := range collector.Diagnostics {
+			got = append(got, position{diagnostic.Line, diagnostic.Column, diagnostic.Message})
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.source, got, test.want)
+		}
+	}
+}
+
+func TestScanInterpolation(t *testing.T) {
+	source := `"a ${b + "c ${d}"} e${f}"`
+	type token struct {
+		Type    TokenType
+		Lexeme  string
+		Literal string
+	}
+	want := []token{
+		{INTERPOLATION, `"a ${`, "a "},
+		{IDENTIFIER, "b", ""},
+		{PLUS, "+", ""},
+		{INTERPOLATION, `"c ${`, "c "},
+		{IDENTIFIER, "d", ""},
+		{INTERPOLATION_END, `}"`, ""},
+		{INTERPOLATION, "} e${", " e"},
+		{IDENTIFIER, "f", ""},
+		{INTERPOLATION_END, `}"`, ""},
+		{EOF, "", ""},
+	}
+	collector := NewDiagnosticCollector("")
+	scanner := NewScanner(source, collector)
+	var got []token
+	for _, scanned := range scanner.ScanTokens() {
+		got = append(got, token{scanned.Type, scanned.Lexeme, scanned.Literal.AsString()})
+	}
+	if collector.HadError() {
+		t.Fatal(collector.Diagnostics)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestScanNumbers(t *testing.T) {
+	tests := []struct {
+		source string
+		want   float64
+	}{
+		{"0", 0},
+		{"42", 42},
+		{"3.25", 3.25},
+		{"0xFF", 255},
+		{"0Xff_ff", 65535},
+		{"0b1010", 10},
+		{"0B1111_0000", 240},
+		{"1_000_000", 1000000},
+		{"1_0.2_5", 10.25},
+		{"1e-9", 1e-9},
+		{"2.5E3", 2500},
+		{"6e+2", 600},
+		{"007", 7},
+	}
+	for _, test := range tests {
+		collector := NewDiagnosticCollector("")
+		scanner := NewScanner(test.source, collector)
+		tokens := scanner.ScanTokens()
+		if collector.HadError() {
+			t.Errorf("%s: %v", test.source, collector.Diagnostics)
+			continue
+		}
+		if len(tokens) != 2 || tokens[0].Type != NUMBER || tokens[0].Literal.AsNumber() != test.want {
+			t.Errorf("%s scanned to %v, want NUMBER %v", test.source, tokens, test.want)
+		}
+	}
+}
+
+func TestScanNumberErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"0x", "Malformed number '0x'."},
+		{"0x_1", "Malformed number '0x_1'."},
+		{"0b102", "Malformed number '0b102'."},
+		{"0b2", "Malformed number '0b2'."},
+		{"1__0", "Malformed number '1__0'."},
+		{"1_", "Malformed number '1_'."},
+		{"1_.5", "Malformed number '1_.5'."},
+		{"1e", "Malformed number '1e'."},
+		{"1e+", "Malformed number '1e+'."},
+		{"12px", "Malformed number '12px'."},
+		{"1e999", "Number '1e999' is out of range."},
+		{"0x1_0000_0000_0000_0000", "Number '0x1_0000_0000_0000_0000' is out of range."},
+		{"٣", "Unexpected digit '٣'; numbers are written with 0-9."},
+		{"１", "Unexpected digit '１'; numbers are written with 0-9."},
+	}
+	for _, test := range tests {
+		collector := NewDiagnosticCollector("")
+		scanner := NewScanner(test.source, collector)
+		scanner.ScanTokens()
+		if len(collector.Diagnostics) == 0 || collector.Diagnostics[0].Message != test.want {
+			t.Errorf("%s: got %v, want %q", test.source, collector.Diagnostics, test.want)
+		}
+	}
+}
+
+// TestNonASCIIDigitReportedOnce checks a digit from another script gets one
+// error, without the parser also finding an expression missing.
+func TestNonASCIIDigitReportedOnce(t *testing.T) {
+	for _, source := range []string{"print ٣;", "var x = １ + 2;"} {
+		collector := NewDiagnosticCollector("")
+		analyze(source, NewInterpreter(), collector)
+		if len(collector.Diagnostics) != 1 || collector.Diagnostics[0].Code != SCAN_ERROR {
+			t.Errorf("%s: got %v, want just the scan error", source, collector.Diagnostics)
+		}
+	}
+}
+
+func TestScanIdentifiers(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"my_var", []string{"my_var"}},
+		{"_", []string{"_"}},
+		{"__init__", []string{"__init__"}},
+		{"x1 _2", []string{"x1", "_2"}},
+		{"café", []string{"café"}},
+		{"π", []string{"π"}},
+		{"变量 имя", []string{"变量", "имя"}},
+		{"é", []string{"é"}},
+		{"x٣", []string{"x٣"}},
+		{"a+b_c", []string{"a", "b_c"}},
+	}
+	for _, test := range tests {
+		collector := NewDiagnosticCollector("")
+		scanner := NewScanner(test.source, collector)
+		tokens := scanner.ScanTokens()
+		if collector.HadError() {
+			t.Errorf("%s: %v", test.source, collector.Diagnostics)
+			continue
+		}
+		var got []string
+		for _, token := range tokens {
+			if token.Type == IDENTIFIER {
+				got = append(got, token.Lexeme)
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got identifiers %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestScanIdentifierErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		// A leading digit begins a number, never an identifier.
+		{"1abc", []string{"Malformed number '1abc'."}},
+		{"2_x", []string{"Malformed number '2_x'."}},
+		// A character outside the identifier rule is reported once, not
+		// once per byte of its encoding.
+		{"a€b", []string{"Unexpected character."}},
+		{"́x", []string{"Unexpected character."}},
+		{"@", []string{"Unexpected character."}},
+	}
+	for _, test := range tests {
+		collector := NewDiagnosticCollector("")
+		scanner := NewScanner(test.source, collector)
+		scanner.ScanTokens()
+		var got []string
+		for _, diagnostic := range collector.Diagnostics {
+			got = append(got, diagnostic.Message)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestScanComments(t *testing.T) {
+	source := "/// Adds one.\n" +
+		"/// Returns a number.\n" +
+		"fun inc(n) { /* a /* nested */ comment */ return n + 1; }\n" +
+		"//// not a doc comment\n" +
+		"// nor this\n" +
+		"/*\n spans\n lines */ print 1 /2;"
+	collector := NewDiagnosticCollector("")
+	scanner := NewScanner(source, collector)
+	tokens := scanner.ScanTokens()
+	if collector.HadError() {
+		t.Fatal(collector.Diagnostics)
+	}
+	var docs []string
+	for _, token := range tokens {
+		if token.Type == DOC_COMMENT {
+			docs = append(docs, token.Literal.String())
+		}
+	}
+	if want := []string{"Adds one.", "Returns a number."}; !reflect.DeepEqual(docs, want) {
+		t.Errorf("got doc comments %q, want %q", docs, want)
+	}
+	// The doc comments come straight before the declaration they document.
+	if tokens[2].Type != FUN || tokens[1].Line != 2 {
+		t.Errorf("got %v, want the doc comments followed by fun", tokens[:3])
+	}
+	print := tokens[len(tokens)-6]
+	if print.Type != PRINT || print.Line != 8 {
+		t.Errorf("got %v on line %d, want print on line 8", print, print.Line)
+	}
+	if slash := tokens[len(tokens)-4]; slash.Type != SLASH {
+		t.Errorf("got %v, want a slash", slash)
+	}
+}
+
+func TestScanCommentErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"/* open", []string{"Unterminated comment."}},
+		{"/* outer /* inner */", []string{"Unterminated comment."}},
+	}
+	for _, test := range tests {
+		collector := NewDiagnosticCollector("")
+		scanner := NewScanner(test.source, collector)
+		scanner.ScanTokens()
+		var got []string
+		for _, diagnostic := range collector.Diagnostics {
+			got = append(got, diagnostic.Message)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+// TestScanPositions checks where tokens start and end, in columns counted
+// in runes and offsets counted in bytes, after a multi-byte character and
+// across line breaks.
+func TestScanPositions(t *testing.T) {
+	source := "var é = 1;\nprint \"ü\nx\";"
+	type position struct {
+		lexeme                                       string
+		line, column, endLine, endColumn, start, end int
+	}
+	want := []position{
+		{"var", 1, 1, 1, 4, 0, 3},
+		{"é", 1, 5, 1, 6, 4, 6},
+		{"=", 1, 7, 1, 8, 7, 8},
+		{"1", 1, 9, 1, 10, 9, 10},
+		{";", 1, 10, 1, 11, 10, 11},
+		{"print", 2, 1, 2, 6, 12, 17},
+		{"\"ü\nx\"", 2, 7, 3, 3, 18, 24},
+		{";", 3, 3, 3, 4, 24, 25},
+		{"", 3, 4, 3, 4, 25, 25},
+	}
+	collector := NewDiagnosticCollector("")
+	scanner := NewScanner(source, collector)
+	tokens := scanner.ScanTokens()
+	if collector.HadError() {
+		t.Fatal(collector.Diagnostics)
+	}
+	var got []position
+	for _, token := range tokens {
+		got = append(got, position{token.Lexeme, token.Line, token.Column, token.EndLine, token.EndColumn, token.Offset, token.EndOffset})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got positions\n%v\nwant\n%v", got, want)
+	}
+}
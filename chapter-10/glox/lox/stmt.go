@@ -1,57 +1,120 @@
-package main
+package lox
 
 type Stmt interface {
 	Accept(v StmtVisitor) (interface{}, error)
 }
 type StmtVisitor interface {
-	VisitBlockStmt(stmt Block) (interface{}, error)
-	VisitExpressionStmt(stmt Expression) (interface{}, error)
-	VisitIfStmt(stmt If) (interface{}, error)
-	VisitPrintStmt(stmt Print) (interface{}, error)
-	VisitVarStmt(stmt Var) (interface{}, error)
-	VisitWhileStmt(stmt While) (interface{}, error)
+	VisitBlockStmt(stmt *Block) (interface{}, error)
+	VisitBreakStmt(stmt *Break) (interface{}, error)
+	VisitClassStmt(stmt *Class) (interface{}, error)
+	VisitContinueStmt(stmt *Continue) (interface{}, error)
+	VisitExpressionStmt(stmt *Expression) (interface{}, error)
+	VisitFunctionStmt(stmt *Function) (interface{}, error)
+	VisitIfStmt(stmt *If) (interface{}, error)
+	VisitPrintStmt(stmt *Print) (interface{}, error)
+	VisitReturnStmt(stmt *Return) (interface{}, error)
+	VisitVarStmt(stmt *Var) (interface{}, error)
+	VisitWhileStmt(stmt *While) (interface{}, error)
 }
 
 type Block struct {
 	statements []Stmt
 }
 
-func NewBlock(statements []Stmt) Block {
-	return Block{
+func NewBlock(statements []Stmt) *Block {
+	return &Block{
 		statements,
 	}
 }
-func (a Block) Accept(visitor StmtVisitor) (interface{}, error) {
+func (a *Block) Accept(visitor StmtVisitor) (interface{}, error) {
 	return visitor.VisitBlockStmt(a)
 }
 
+type Break struct {
+	keyword Token
+}
+
+func NewBreak(keyword Token) *Break {
+	return &Break{
+		keyword,
+	}
+}
+func (a *Break) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitBreakStmt(a)
+}
+
+type Class struct {
+	name    Token
+	methods []*Function
+}
+
+func NewClass(name Token, methods []*Function) *Class {
+	return &Class{
+		name,
+		methods,
+	}
+}
+func (a *Class) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitClassStmt(a)
+}
+
+type Continue struct {
+	keyword Token
+}
+
+func NewContinue(keyword Token) *Continue {
+	return &Continue{
+		keyword,
+	}
+}
+func (a *Continue) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitContinueStmt(a)
+}
+
 type Expression struct {
 	expression Expr
 }
 
-func NewExpression(expression Expr) Expression {
-	return Expression{
+func NewExpression(expression Expr) *Expression {
+	return &Expression{
 		expression,
 	}
 }
-func (a Expression) Accept(visitor StmtVisitor) (interface{}, error) {
+func (a *Expression) Accept(visitor StmtVisitor) (interface{}, error) {
 	return visitor.VisitExpressionStmt(a)
 }
 
+type Function struct {
+	name   Token
+	params []Token
+	body   []Stmt
+}
+
+func NewFunction(name Token, params []Token, body []Stmt) *Function {
+	return &Function{
+		name,
+		params,
+		body,
+	}
+}
+func (a *Function) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitFunctionStmt(a)
+}
+
 type If struct {
 	condition  Expr
 	thenBranch Stmt
 	elseBranch Stmt
 }
 
-func NewIf(condition Expr, thenBranch Stmt, elseBranch Stmt) If {
-	return If{
+func NewIf(condition Expr, thenBranch Stmt, elseBranch Stmt) *If {
+	return &If{
 		condition,
 		thenBranch,
 		elseBranch,
 	}
 }
-func (a If) Accept(visitor StmtVisitor) (interface{}, error) {
+func (a *If) Accept(visitor StmtVisitor) (interface{}, error) {
 	return visitor.VisitIfStmt(a)
 }
 
@@ -59,41 +122,60 @@ type Print struct {
 	expression Expr
 }
 
-func NewPrint(expression Expr) Print {
-	return Print{
+func NewPrint(expression Expr) *Print {
+	return &Print{
 		expression,
 	}
 }
-func (a Print) Accept(visitor StmtVisitor) (interface{}, error) {
+func (a *Print) Accept(visitor StmtVisitor) (interface{}, error) {
 	return visitor.VisitPrintStmt(a)
 }
 
+type Return struct {
+	keyword Token
+	value   Expr
+}
+
+func NewReturn(keyword Token, value Expr) *Return {
+	return &Return{
+		keyword,
+		value,
+	}
+}
+func (a *Return) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitReturnStmt(a)
+}
+
 type Var struct {
 	name        Token
 	initializer Expr
 }
 
-func NewVar(name Token, initializer Expr) Var {
-	return Var{
+func NewVar(name Token, initializer Expr) *Var {
+	return &Var{
 		name,
 		initializer,
 	}
 }
-func (a Var) Accept(visitor StmtVisitor) (interface{}, error) {
+func (a *Var) Accept(visitor StmtVisitor) (interface{}, error) {
 	return visitor.VisitVarStmt(a)
 }
 
 type While struct {
+	keyword   Token
 	condition Expr
 	body      Stmt
+	increment Expr
 }
 
-func NewWhile(condition Expr, body Stmt) While {
-	return While{
+func NewWhile(keyword Token, condition Expr, body Stmt, increment Expr) *While {
+	return &While{
+		keyword,
 		condition,
 		body,
+		increment,
 	}
 }
-func (a While) Accept(visitor StmtVisitor) (interface{}, error) {
+func (a *While) Accept(visitor StmtVisitor) (interface{}, error) {
 	return visitor.VisitWhileStmt(a)
 }
@@ -0,0 +1,61 @@
+package lox
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// Host is what a native function can use of the Interpreter or VM calling
+// it.
+type Host interface {
+	// Output is where print writes.
+	Output() io.Writer
+	// ReadLine returns the next line of input without its line ending, or
+	// false once the input is exhausted.
+	ReadLine() (string, bool)
+}
+
+// streams is the input and output a program sees. Both backends embed it,
+// which makes them Hosts.
+type streams struct {
+	stdout io.Writer
+	stdin  *bufio.Reader
+}
+
+func newStreams() streams {
+	return streams{
+		stdout: os.Stdout,
+		stdin:  bufio.NewReader(os.Stdin),
+	}
+}
+
+// SetOutput sends everything print writes to w instead of stdout.
+func (s *streams) SetOutput(w io.Writer) {
+	s.stdout = w
+}
+
+// SetInput makes readLine() read from r instead of stdin. A *bufio.Reader
+// is read from directly, so a caller sharing it with the program, such as
+// the REPL, sees no input go missing into a second buffer.
+func (s *streams) SetInput(r io.Reader) {
+	if reader, isBuffered := r.(*bufio.Reader); isBuffered {
+		s.stdin = reader
+		return
+	}
+	s.stdin = bufio.NewReader(r)
+}
+
+func (s *streams) Output() io.Writer {
+	return s.stdout
+}
+
+func (s *streams) ReadLine() (string, bool) {
+	line, err := s.stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), true
+}
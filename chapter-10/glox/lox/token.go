@@ -1,16 +1,25 @@
-package main
+package lox
 
 import "fmt"
 
+// Token is a single lexeme. Line and Column locate its first character,
+// EndLine and EndColumn the position just past its last one. Columns are
+// 1-based and counted in runes; Offset and EndOffset are byte offsets into
+// the source.
 type Token struct {
-	Type    TokenType
-	Lexeme  string
-	Literal interface{}
-	Line    int
+	Type      TokenType
+	Lexeme    string
+	Literal   Value
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+	Offset    int
+	EndOffset int
 }
 
 // NewToken is a constructor function for creating Token instances.
-func NewToken(tokenType TokenType, lexeme string, literal interface{}, line int) Token {
+func NewToken(tokenType TokenType, lexeme string, literal Value, line int) Token {
 	return Token{
 		Type:    tokenType,
 		Lexeme:  lexeme,
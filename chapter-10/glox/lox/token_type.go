@@ -1,4 +1,4 @@
-package main
+package lox
 
 type TokenType int
 
@@ -30,10 +30,23 @@ const (
 	IDENTIFIER
 	STRING
 	NUMBER
+	// INTERPOLATION is the part of a string literal up to a "${". The
+	// expression inside follows, then the rest of the string as another
+	// INTERPOLATION or, for the last part, an INTERPOLATION_END.
+	INTERPOLATION
+	INTERPOLATION_END
+
+	// Trivia
+	// DOC_COMMENT is a /// comment, kept so tools can attach it to the
+	// declaration that follows. Its literal is the comment's text. The
+	// parser skips it.
+	DOC_COMMENT
 
 	// Keywords
 	AND
+	BREAK
 	CLASS
+	CONTINUE
 	ELSE
 	FALSE
 	FUN
@@ -54,43 +67,48 @@ const (
 )
 
 var TokenName = map[TokenType]string{
-	LEFT_PAREN:    "LEFT_PAREN",
-	RIGHT_PAREN:   "RIGHT_PAREN",
-	LEFT_BRACE:    "LEFT_BRACE",
-	RIGHT_BRACE:   "RIGHT_BRACE",
-	COMMA:         "COMMA",
-	DOT:           "DOT",
-	MINUS:         "MINUS",
-	PLUS:          "PLUS",
-	SEMICOLON:     "SEMICOLON",
-	SLASH:         "SLASH",
-	STAR:          "STAR",
-	BANG:          "BANG",
-	BANG_EQUAL:    "BANG_EQUAL",
-	EQUAL:         "EQUAL",
-	EQUAL_EQUAL:   "EQUAL_EQUAL",
-	GREATER:       "GREATER",
-	GREATER_EQUAL: "GREATER_EQUAL",
-	LESS:          "LESS",
-	LESS_EQUAL:    "LESS_EQUAL",
-	IDENTIFIER:    "IDENTIFIER",
-	STRING:        "STRING",
-	NUMBER:        "NUMBER",
-	AND:           "AND",
-	CLASS:         "CLASS",
-	ELSE:          "ELSE",
-	FALSE:         "FALSE",
-	FUN:           "FUN",
-	FOR:           "FOR",
-	IF:            "IF",
-	NIL:           "NIL",
-	OR:            "OR",
-	PRINT:         "PRINT",
-	RETURN:        "RETURN",
-	SUPER:         "SUPER",
-	THIS:          "THIS",
-	TRUE:          "TRUE",
-	VAR:           "VAR",
-	WHILE:         "WHILE",
-	EOF:           "EOF",
+	LEFT_PAREN:        "LEFT_PAREN",
+	RIGHT_PAREN:       "RIGHT_PAREN",
+	LEFT_BRACE:        "LEFT_BRACE",
+	RIGHT_BRACE:       "RIGHT_BRACE",
+	COMMA:             "COMMA",
+	DOT:               "DOT",
+	MINUS:             "MINUS",
+	PLUS:              "PLUS",
+	SEMICOLON:         "SEMICOLON",
+	SLASH:             "SLASH",
+	STAR:              "STAR",
+	BANG:              "BANG",
+	BANG_EQUAL:        "BANG_EQUAL",
+	EQUAL:             "EQUAL",
+	EQUAL_EQUAL:       "EQUAL_EQUAL",
+	GREATER:           "GREATER",
+	GREATER_EQUAL:     "GREATER_EQUAL",
+	LESS:              "LESS",
+	LESS_EQUAL:        "LESS_EQUAL",
+	IDENTIFIER:        "IDENTIFIER",
+	STRING:            "STRING",
+	NUMBER:            "NUMBER",
+	INTERPOLATION:     "INTERPOLATION",
+	INTERPOLATION_END: "INTERPOLATION_END",
+	DOC_COMMENT:       "DOC_COMMENT",
+	AND:               "AND",
+	BREAK:             "BREAK",
+	CLASS:             "CLASS",
+	CONTINUE:          "CONTINUE",
+	ELSE:              "ELSE",
+	FALSE:             "FALSE",
+	FUN:               "FUN",
+	FOR:               "FOR",
+	IF:                "IF",
+	NIL:               "NIL",
+	OR:                "OR",
+	PRINT:             "PRINT",
+	RETURN:            "RETURN",
+	SUPER:             "SUPER",
+	THIS:              "THIS",
+	TRUE:              "TRUE",
+	VAR:               "VAR",
+	WHILE:             "WHILE",
+	EOF:               "EOF",
 }
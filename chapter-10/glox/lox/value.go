@@ -0,0 +1,130 @@
+package lox
+
+import "strconv"
+
+type ValueType int
+
+const (
+	VAL_NIL ValueType = iota
+	VAL_BOOL
+	VAL_NUMBER
+	VAL_STRING
+	VAL_CALLABLE
+	VAL_OBJECT
+)
+
+// Callable is implemented by every value Lox code can call. Each backend
+// calls its own: the tree-walker through LoxCallable, the VM by pushing a
+// call frame.
+type Callable interface {
+	Arity() int
+	String() string
+}
+
+// Object is implemented by class instances, the only values with fields. A
+// string the VM built at runtime also keeps the heap object that accounts
+// for it here.
+type Object interface {
+	String() string
+}
+
+// Value is a Lox runtime value. Type says which of the fields holds it; the
+// zero Value is nil.
+type Value struct {
+	Type     ValueType
+	boolean  bool
+	number   float64
+	str      string
+	callable Callable
+	object   Object
+}
+
+func NilValue() Value {
+	return Value{}
+}
+
+func BoolValue(boolean bool) Value {
+	return Value{Type: VAL_BOOL, boolean: boolean}
+}
+
+func NumberValue(number float64) Value {
+	return Value{Type: VAL_NUMBER, number: number}
+}
+
+func StringValue(str string) Value {
+	return Value{Type: VAL_STRING, str: str}
+}
+
+func CallableValue(callable Callable) Value {
+	return Value{Type: VAL_CALLABLE, callable: callable}
+}
+
+func ObjectValue(object Object) Value {
+	return Value{Type: VAL_OBJECT, object: object}
+}
+
+func (v Value) IsNil() bool      { return v.Type == VAL_NIL }
+func (v Value) IsBool() bool     { return v.Type == VAL_BOOL }
+func (v Value) IsNumber() bool   { return v.Type == VAL_NUMBER }
+func (v Value) IsString() bool   { return v.Type == VAL_STRING }
+func (v Value) IsCallable() bool { return v.Type == VAL_CALLABLE }
+func (v Value) IsObject() bool   { return v.Type == VAL_OBJECT }
+
+func (v Value) AsBool() bool         { return v.boolean }
+func (v Value) AsNumber() float64    { return v.number }
+func (v Value) AsString() string     { return v.str }
+func (v Value) AsCallable() Callable { return v.callable }
+func (v Value) AsObject() Object     { return v.object }
+
+// IsTruthy follows Ruby: nil and false are falsey, everything else is truthy.
+func (v Value) IsTruthy() bool {
+	switch v.Type {
+	case VAL_NIL:
+		return false
+	case VAL_BOOL:
+		return v.boolean
+	default:
+		return true
+	}
+}
+
+// Equal never converts between types, so 0 == false and "1" == 1 are both
+// false. Callables and objects are equal only to themselves.
+func (v Value) Equal(other Value) bool {
+	if v.Type != other.Type {
+		return false
+	}
+	switch v.Type {
+	case VAL_NIL:
+		return true
+	case VAL_BOOL:
+		return v.boolean == other.boolean
+	case VAL_NUMBER:
+		return v.number == other.number
+	case VAL_STRING:
+		return v.str == other.str
+	case VAL_CALLABLE:
+		return v.callable == other.callable
+	default:
+		return v.object == other.object
+	}
+}
+
+// String formats the value the way print shows it: integral numbers drop
+// their fraction and strings print without quotes.
+func (v Value) String() string {
+	switch v.Type {
+	case VAL_BOOL:
+		return strconv.FormatBool(v.boolean)
+	case VAL_NUMBER:
+		return strconv.FormatFloat(v.number, 'f', -1, 64)
+	case VAL_STRING:
+		return v.str
+	case VAL_CALLABLE:
+		return v.callable.String()
+	case VAL_OBJECT:
+		return v.object.String()
+	default:
+		return "nil"
+	}
+}
@@ -0,0 +1,516 @@
+package lox
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const framesMax = 1024
+
+// callFrame is one function call in progress. slots is the index of the
+// stack slot holding the callee, which locals are numbered from.
+type callFrame struct {
+	closure *vmClosure
+	ip      int
+	slots   int
+}
+
+// VM is a stack-based virtual machine running the bytecode the compiler
+// produces from the same syntax tree the Interpreter walks. Like the
+// Interpreter it keeps its globals from one run to the next.
+//
+// Everything the VM allocates lives on a mark-sweep collected heap rooted
+// at the stack, the globals and the open upvalues. StressGC collects before
+// every allocation, to shake out objects the VM forgot to root, and GCLog,
+// when set, receives a line for each collection.
+type VM struct {
+	streams
+	StressGC bool
+	GCLog    io.Writer
+
+	frames       [framesMax]callFrame
+	frameCount   int
+	stack        []Value
+	globals      map[string]Value
+	openUpvalues *vmUpvalue
+	heap         *heap
+}
+
+func NewVM() *VM {
+	vm := &VM{
+		stack:   make([]Value, 0, 256),
+		globals: make(map[string]Value),
+		heap:    newHeap(),
+		streams: newStreams(),
+	}
+	for _, native := range natives {
+		vm.globals[native.name] = CallableValue(native)
+	}
+	return vm
+}
+
+// DefineNative registers a Go function as a global Lox function.
+func (vm *VM) DefineNative(name string, arity int, fn func(host Host, arguments []Value) (Value, error)) {
+	vm.globals[name] = CallableValue(NewNativeFunction(name, arity, fn))
+}
+
+// Run compiles and runs source on the VM.
+func (vm *VM) Run(source string) (Result, []Diagnostic) {
+	return vm.RunSource("", source)
+}
+
+// RunSource is Run for source read from file, which every diagnostic is
+// attributed to.
+func (vm *VM) RunSource(file string, source string) (Result, []Diagnostic) {
+	return vm.runSource(file, source, false)
+}
+
+// RunPrompt is Run for a line typed at the REPL, the same way as
+// Interpreter.RunPrompt.
+func (vm *VM) RunPrompt(source string) (Result, []Diagnostic) {
+	return vm.runSource("", source, true)
+}
+
+func (vm *VM) runSource(file string, source string, prompt bool) (Result, []Diagnostic) {
+	collector := NewDiagnosticCollector(file)
+	statements := analyzeSource(source, nil, collector, prompt)
+	if collector.HadError() {
+		return INTERPRET_COMPILE_ERROR, collector.Diagnostics
+	}
+
+	function := newCompiler(collector).compile(statements)
+	if collector.HadError() {
+		return INTERPRET_COMPILE_ERROR, collector.Diagnostics
+	}
+
+	err := vm.interpret(function)
+	if runtimeError, isRuntimeError := err.(RuntimeError); isRuntimeError {
+		collector.Report(newRuntimeDiagnostic(runtimeError))
+		return INTERPRET_RUNTIME_ERROR, collector.Diagnostics
+	}
+	return INTERPRET_OK, collector.Diagnostics
+}
+
+// DumpEnvironment writes the global variables. Between runs they are the
+// only ones in scope.
+func (vm *VM) DumpEnvironment(w io.Writer) {
+	dumpGlobals(w, vm.globals)
+}
+
+// GlobalNames returns the names of the global variables, sorted.
+func (vm *VM) GlobalNames() []string {
+	return sortedNames(vm.globals)
+}
+
+// GCStats reports what the garbage collector has done so far.
+func (vm *VM) GCStats() GCStats {
+	return vm.heap.stats
+}
+
+func (vm *VM) interpret(function *vmFunction) error {
+	vm.adopt(function)
+	// Keep the script rooted while its closure is allocated.
+	vm.push(CallableValue(function))
+	closure := newVMClosure(function)
+	vm.allocate(closure)
+	vm.pop()
+	vm.push(CallableValue(closure))
+	err := vm.call(closure, 0)
+	if err == nil {
+		err = vm.run()
+	}
+	if err != nil {
+		// Unwind so the next run starts from a clean stack.
+		vm.stack = vm.stack[:0]
+		vm.frameCount = 0
+		vm.openUpvalues = nil
+	}
+	return err
+}
+
+func (vm *VM) run() error {
+	frame := &vm.frames[vm.frameCount-1]
+	for {
+		op := OpCode(vm.readByte(frame))
+		switch op {
+		case OP_CONSTANT:
+			vm.push(vm.readConstant(frame))
+		case OP_NIL:
+			vm.push(NilValue())
+		case OP_TRUE:
+			vm.push(BoolValue(true))
+		case OP_FALSE:
+			vm.push(BoolValue(false))
+		case OP_POP:
+			vm.pop()
+		case OP_GET_LOCAL:
+			slot := int(vm.readByte(frame))
+			vm.push(vm.stack[frame.slots+slot])
+		case OP_SET_LOCAL:
+			slot := int(vm.readByte(frame))
+			vm.stack[frame.slots+slot] = vm.peek(0)
+		case OP_GET_GLOBAL:
+			name := vm.readConstant(frame).AsString()
+			value, exists := vm.globals[name]
+			if !exists {
+				return vm.runtimeError("Undefined variable '" + name + "'.")
+			}
+			vm.push(value)
+		case OP_DEFINE_GLOBAL:
+			name := vm.readConstant(frame).AsString()
+			vm.globals[name] = vm.pop()
+		case OP_SET_GLOBAL:
+			name := vm.readConstant(frame).AsString()
+			if _, exists := vm.globals[name]; !exists {
+				return vm.runtimeError("Undefined variable '" + name + "'.")
+			}
+			vm.globals[name] = vm.peek(0)
+		case OP_GET_UPVALUE:
+			upvalue := frame.closure.upvalues[vm.readByte(frame)]
+			if upvalue.open {
+				vm.push(vm.stack[upvalue.slot])
+			} else {
+				vm.push(upvalue.closed)
+			}
+		case OP_SET_UPVALUE:
+			upvalue := frame.closure.upvalues[vm.readByte(frame)]
+			if upvalue.open {
+				vm.stack[upvalue.slot] = vm.peek(0)
+			} else {
+				upvalue.closed = vm.peek(0)
+			}
+		case OP_GET_PROPERTY:
+			name := vm.readConstant(frame).AsString()
+			instance, isInstance := vm.peek(0).AsObject().(*vmInstance)
+			if !isInstance {
+				return vm.runtimeError("Only instances have properties.")
+			}
+			// Fields shadow methods of the same name.
+			if value, exists := instance.fields[name]; exists {
+				vm.pop()
+				vm.push(value)
+				break
+			}
+			method, exists := instance.class.methods[name]
+			if !exists {
+				return vm.runtimeError("Undefined property '" + name + "'.")
+			}
+			bound := &vmBoundMethod{receiver: vm.peek(0), method: method}
+			vm.allocate(bound)
+			vm.pop()
+			vm.push(CallableValue(bound))
+		case OP_SET_PROPERTY:
+			name := vm.readConstant(frame).AsString()
+			instance, isInstance := vm.peek(1).AsObject().(*vmInstance)
+			if !isInstance {
+				return vm.runtimeError("Only instances have fields.")
+			}
+			value := vm.pop()
+			if _, exists := instance.fields[name]; !exists {
+				vm.heap.grow(instance, entrySize(name))
+			}
+			instance.fields[name] = value
+			vm.pop()
+			vm.push(value)
+		case OP_EQUAL:
+			b := vm.pop()
+			a := vm.pop()
+			vm.push(BoolValue(a.Equal(b)))
+		case OP_GREATER, OP_GREATER_EQUAL, OP_LESS, OP_LESS_EQUAL, OP_SUBTRACT, OP_MULTIPLY, OP_DIVIDE:
+			if !vm.peek(0).IsNumber() || !vm.peek(1).IsNumber() {
+				return vm.runtimeError("Operands must be numbers.")
+			}
+			b := vm.pop().AsNumber()
+			a := vm.pop().AsNumber()
+			vm.push(numberOp(op, a, b))
+		case OP_ADD:
+			b := vm.peek(0)
+			a := vm.peek(1)
+			switch {
+			case a.IsNumber() && b.IsNumber():
+				vm.pop()
+				vm.pop()
+				vm.push(NumberValue(a.AsNumber() + b.AsNumber()))
+			case a.IsString() && b.IsString():
+				result := vm.newString(a.AsString() + b.AsString())
+				vm.pop()
+				vm.pop()
+				vm.push(result)
+			default:
+				return vm.runtimeError("Operands must be two numbers or two strings.")
+			}
+		case OP_INTERPOLATE:
+			count := int(vm.readByte(frame))
+			parts := vm.stack[len(vm.stack)-count:]
+			var builder strings.Builder
+			for _, part := range parts {
+				builder.WriteString(part.String())
+			}
+			// The parts stay on the stack until the result is allocated,
+			// in case that collects garbage.
+			result := vm.newString(builder.String())
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(result)
+		case OP_NOT:
+			vm.push(BoolValue(!vm.pop().IsTruthy()))
+		case OP_NEGATE:
+			if !vm.peek(0).IsNumber() {
+				return vm.runtimeError("Operand must be a number.")
+			}
+			vm.push(NumberValue(-vm.pop().AsNumber()))
+		case OP_PRINT:
+			fmt.Fprintln(vm.stdout, vm.pop().String())
+		case OP_JUMP:
+			offset := vm.readShort(frame)
+			frame.ip += offset
+		case OP_JUMP_IF_FALSE:
+			offset := vm.readShort(frame)
+			if !vm.peek(0).IsTruthy() {
+				frame.ip += offset
+			}
+		case OP_LOOP:
+			offset := vm.readShort(frame)
+			frame.ip -= offset
+		case OP_CALL:
+			argCount := int(vm.readByte(frame))
+			err := vm.callValue(vm.peek(argCount), argCount)
+			if err != nil {
+				return err
+			}
+			frame = &vm.frames[vm.frameCount-1]
+		case OP_CLOSURE:
+			function := vm.readConstant(frame).AsCallable().(*vmFunction)
+			closure := newVMClosure(function)
+			vm.allocate(closure)
+			vm.push(CallableValue(closure))
+			for i := range closure.upvalues {
+				isLocal := vm.readByte(frame)
+				index := int(vm.readByte(frame))
+				if isLocal == 1 {
+					closure.upvalues[i] = vm.captureUpvalue(frame.slots + index)
+				} else {
+					closure.upvalues[i] = frame.closure.upvalues[index]
+				}
+			}
+		case OP_CLOSE_UPVALUE:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+		case OP_RETURN:
+			result := vm.pop()
+			vm.closeUpvalues(frame.slots)
+			vm.frameCount--
+			if vm.frameCount == 0 {
+				vm.pop()
+				return nil
+			}
+			vm.stack = vm.stack[:frame.slots]
+			vm.push(result)
+			frame = &vm.frames[vm.frameCount-1]
+		case OP_CLASS:
+			class := newVMClass(vm.readConstant(frame).AsString())
+			vm.allocate(class)
+			vm.push(CallableValue(class))
+		case OP_METHOD:
+			name := vm.readConstant(frame).AsString()
+			method := vm.pop().AsCallable().(*vmClosure)
+			class := vm.peek(0).AsCallable().(*vmClass)
+			vm.heap.grow(class, entrySize(name))
+			class.methods[name] = method
+		}
+	}
+}
+
+func numberOp(op OpCode, a float64, b float64) Value {
+	switch op {
+	case OP_GREATER:
+		return BoolValue(a > b)
+	case OP_GREATER_EQUAL:
+		return BoolValue(a >= b)
+	case OP_LESS:
+		return BoolValue(a < b)
+	case OP_LESS_EQUAL:
+		return BoolValue(a <= b)
+	case OP_SUBTRACT:
+		return NumberValue(a - b)
+	case OP_MULTIPLY:
+		return NumberValue(a * b)
+	default:
+		return NumberValue(a / b)
+	}
+}
+
+// callValue calls callee with the argCount arguments above it on the stack.
+// Calls to Lox functions only push a frame; run picks it up from there.
+func (vm *VM) callValue(callee Value, argCount int) error {
+	switch callee := callee.AsCallable().(type) {
+	case *vmClosure:
+		return vm.call(callee, argCount)
+	case *vmBoundMethod:
+		vm.stack[len(vm.stack)-argCount-1] = callee.receiver
+		return vm.call(callee.method, argCount)
+	case *vmClass:
+		instance := newVMInstance(callee)
+		vm.allocate(instance)
+		vm.stack[len(vm.stack)-argCount-1] = ObjectValue(instance)
+		if initializer, exists := callee.methods["init"]; exists {
+			return vm.call(initializer, argCount)
+		}
+		if argCount != 0 {
+			return vm.runtimeError(fmt.Sprintf("Expected 0 arguments but got %d.", argCount))
+		}
+		return nil
+	case *NativeFunction:
+		if argCount != callee.arity {
+			return vm.runtimeError(fmt.Sprintf("Expected %d arguments but got %d.", callee.arity, argCount))
+		}
+		arguments := append([]Value(nil), vm.stack[len(vm.stack)-argCount:]...)
+		result, err := callee.fn(vm, arguments)
+		if err != nil {
+			return nativeError(err, vm.runtimeError)
+		}
+		vm.stack = vm.stack[:len(vm.stack)-argCount-1]
+		vm.push(result)
+		return nil
+	}
+	return vm.runtimeError("Can only call functions and classes.")
+}
+
+func (vm *VM) call(closure *vmClosure, argCount int) error {
+	if argCount != closure.function.arity {
+		return vm.runtimeError(fmt.Sprintf("Expected %d arguments but got %d.", closure.function.arity, argCount))
+	}
+	if vm.frameCount == framesMax {
+		overflow := vm.runtimeError("Stack overflow.")
+		overflow.Kind = RUNTIME_STACK_OVERFLOW
+		return overflow
+	}
+	vm.frames[vm.frameCount] = callFrame{
+		closure: closure,
+		slots:   len(vm.stack) - argCount - 1,
+	}
+	vm.frameCount++
+	return nil
+}
+
+// captureUpvalue returns the open upvalue for slot, creating it if no
+// closure has captured the slot yet. Open upvalues are kept sorted by slot,
+// highest first, so closeUpvalues can stop early.
+func (vm *VM) captureUpvalue(slot int) *vmUpvalue {
+	var previous *vmUpvalue
+	upvalue := vm.openUpvalues
+	for upvalue != nil && upvalue.slot > slot {
+		previous = upvalue
+		upvalue = upvalue.next
+	}
+	if upvalue != nil && upvalue.slot == slot {
+		return upvalue
+	}
+	created := &vmUpvalue{slot: slot, open: true, next: upvalue}
+	vm.allocate(created)
+	if previous == nil {
+		vm.openUpvalues = created
+	} else {
+		previous.next = created
+	}
+	return created
+}
+
+// closeUpvalues moves every open upvalue at or above last off the stack.
+func (vm *VM) closeUpvalues(last int) {
+	for vm.openUpvalues != nil && vm.openUpvalues.slot >= last {
+		upvalue := vm.openUpvalues
+		upvalue.closed = vm.stack[upvalue.slot]
+		upvalue.open = false
+		vm.openUpvalues = upvalue.next
+	}
+}
+
+// runtimeError reports message against the token the instruction being
+// executed was compiled from.
+func (vm *VM) runtimeError(message string) RuntimeError {
+	frame := &vm.frames[vm.frameCount-1]
+	chunk := &frame.closure.function.chunk
+	offset := frame.ip - 1
+	location := Token{
+		Line:      chunk.Lines[offset],
+		Column:    chunk.Columns[offset],
+		Offset:    chunk.Spans[offset].Start,
+		EndOffset: chunk.Spans[offset].End,
+	}
+	return RuntimeError{Operator: location, Message: message}
+}
+
+// allocate puts object on the heap, collecting first if the heap has
+// outgrown its threshold. Until object is reachable, whatever it refers to
+// must stay reachable some other way, typically from the stack.
+func (vm *VM) allocate(object heapObject) {
+	if vm.StressGC || vm.heap.live > vm.heap.nextGC {
+		vm.collectGarbage()
+	}
+	vm.heap.link(object)
+}
+
+// adopt puts a freshly compiled function and every function nested in it
+// on the heap. Nothing can be collected in between, since none of them is
+// rooted yet.
+func (vm *VM) adopt(function *vmFunction) {
+	vm.heap.link(function)
+	for _, constant := range function.chunk.Constants {
+		if nested, isFunction := constant.AsCallable().(*vmFunction); isFunction {
+			vm.adopt(nested)
+		}
+	}
+}
+
+func (vm *VM) newString(chars string) Value {
+	object := &vmString{chars: chars}
+	vm.allocate(object)
+	return Value{Type: VAL_STRING, str: chars, object: object}
+}
+
+func (vm *VM) collectGarbage() {
+	for _, value := range vm.stack {
+		vm.heap.markValue(value)
+	}
+	for i := 0; i < vm.frameCount; i++ {
+		vm.heap.markObject(vm.frames[i].closure)
+	}
+	for upvalue := vm.openUpvalues; upvalue != nil; upvalue = upvalue.next {
+		vm.heap.markObject(upvalue)
+	}
+	for _, value := range vm.globals {
+		vm.heap.markValue(value)
+	}
+	vm.heap.collect(vm.GCLog)
+}
+
+func (vm *VM) readByte(frame *callFrame) byte {
+	b := frame.closure.function.chunk.Code[frame.ip]
+	frame.ip++
+	return b
+}
+
+func (vm *VM) readShort(frame *callFrame) int {
+	code := frame.closure.function.chunk.Code
+	frame.ip += 2
+	return int(code[frame.ip-2])<<8 | int(code[frame.ip-1])
+}
+
+func (vm *VM) readConstant(frame *callFrame) Value {
+	return frame.closure.function.chunk.Constants[vm.readShort(frame)]
+}
+
+func (vm *VM) push(value Value) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() Value {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+func (vm *VM) peek(distance int) Value {
+	return vm.stack[len(vm.stack)-1-distance]
+}
@@ -0,0 +1,112 @@
+package lox
+
+// vmFunction is a compiled function body. The top-level script compiles to
+// one with an empty name.
+type vmFunction struct {
+	objectHeader
+	name         string
+	arity        int
+	upvalueCount int
+	chunk        Chunk
+}
+
+func (f *vmFunction) Arity() int {
+	return f.arity
+}
+
+func (f *vmFunction) String() string {
+	if f.name == "" {
+		return "<script>"
+	}
+	return "<fn " + f.name + ">"
+}
+
+// vmUpvalue is a variable captured by a closure. While open it refers to a
+// slot on the VM stack; once that slot is popped the value moves into closed.
+type vmUpvalue struct {
+	objectHeader
+	slot   int
+	open   bool
+	closed Value
+	next   *vmUpvalue
+}
+
+type vmClosure struct {
+	objectHeader
+	function *vmFunction
+	upvalues []*vmUpvalue
+}
+
+func newVMClosure(function *vmFunction) *vmClosure {
+	return &vmClosure{
+		function: function,
+		upvalues: make([]*vmUpvalue, function.upvalueCount),
+	}
+}
+
+func (c *vmClosure) Arity() int {
+	return c.function.arity
+}
+
+func (c *vmClosure) String() string {
+	return c.function.String()
+}
+
+type vmClass struct {
+	objectHeader
+	name    string
+	methods map[string]*vmClosure
+}
+
+func newVMClass(name string) *vmClass {
+	return &vmClass{
+		name:    name,
+		methods: make(map[string]*vmClosure),
+	}
+}
+
+// Arity is the arity of the initializer, if the class declares one.
+func (c *vmClass) Arity() int {
+	initializer, exists := c.methods["init"]
+	if !exists {
+		return 0
+	}
+	return initializer.Arity()
+}
+
+func (c *vmClass) String() string {
+	return c.name
+}
+
+type vmInstance struct {
+	objectHeader
+	class  *vmClass
+	fields map[string]Value
+}
+
+func newVMInstance(class *vmClass) *vmInstance {
+	return &vmInstance{
+		class:  class,
+		fields: make(map[string]Value),
+	}
+}
+
+func (i *vmInstance) String() string {
+	return i.class.name + " instance"
+}
+
+// vmBoundMethod is a method read off an instance, which becomes "this" in
+// slot 0 when it is called.
+type vmBoundMethod struct {
+	objectHeader
+	receiver Value
+	method   *vmClosure
+}
+
+func (b *vmBoundMethod) Arity() int {
+	return b.method.Arity()
+}
+
+func (b *vmBoundMethod) String() string {
+	return b.method.String()
+}
@@ -20,7 +20,7 @@ func (p *Parser) Parse() []Stmt {
 	var statements []Stmt
 	for !p.isAtEnd() {
 		stmt, err := p.declaration()
-		if err == nil {
+		if err == nil && stmt != nil {
 			statements = append(statements, stmt)
 		}
 	}
@@ -160,7 +160,9 @@ func (p *Parser) ifStatement() (Stmt, error) {
 		return nil, err
 	}
 	err = p.consume(RIGHT_PAREN, "Expect ')' after if condition.")
-
+	if err != nil {
+		return nil, err
+	}
 	thenBranch, err = p.statement()
 	if err != nil {
 		return nil, err
@@ -180,6 +182,9 @@ func (p *Parser) whileStatement() (Stmt, error) {
 		return nil, err
 	}
 	condition, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
 	err = p.consume(RIGHT_PAREN, "Expect ')' after while condition.")
 	if err != nil {
 		return nil, err
@@ -198,7 +203,10 @@ func (p *Parser) blockStatement() (Stmt, error) {
 		if err != nil {
 			return nil, err
 		}
-		statements = append(statements, stmt)
+		// A nil statement means declaration hit an error and synchronized.
+		if stmt != nil {
+			statements = append(statements, stmt)
+		}
 	}
 	err := p.consume(RIGHT_BRACE, "Expect '}' after block.")
 	if err != nil {
@@ -447,5 +455,18 @@ func (p *Parser) error(token Token, message string) error {
 	return errors.New("ParseError")
 }
 
+// synchronize discards tokens until it reaches what is probably the start of
+// the next statement: just past a semicolon or at a keyword that begins one.
 func (p *Parser) synchronize() {
+	p.advance()
+	for !p.isAtEnd() {
+		if p.previous().Type == SEMICOLON {
+			return
+		}
+		switch p.peek().Type {
+		case CLASS, FUN, VAR, FOR, IF, WHILE, PRINT, RETURN:
+			return
+		}
+		p.advance()
+	}
 }
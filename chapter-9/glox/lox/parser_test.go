@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestParserRecoversFromErrors checks each unrelated syntax error is
+// reported once, on its own line, and the statements around them still
+// parse.
+func TestParserRecoversFromErrors(t *testing.T) {
+	source := "var = 1;\n" +
+		"print 2 +;\n" +
+		"var ok = 3;\n" +
+		"print (4;\n" +
+		"print ok;\n"
+	var statements []Stmt
+	got := captureStderr(t, func() {
+		scanner := NewScanner(source)
+		parser := NewParser(scanner.ScanTokens())
+		statements = parser.Parse()
+	})
+	hadError = false
+	want := "[line 1] Error  at '=': Expect variable name\n" +
+		"[line 2] Error  at ';': Expect expression\n" +
+		"[line 4] Error  at ';': Expect ')' after expression.\n"
+	if got != want {
+		t.Errorf("got errors\n%s\nwant\n%s", got, want)
+	}
+	if len(statements) != 2 {
+		t.Errorf("got %d statements, want the 2 without errors", len(statements))
+	}
+}
+
+// captureStderr returns what run writes to stderr, where errors are
+// reported.
+func captureStderr(t *testing.T, run func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = writer
+	defer func() {
+		os.Stderr = stderr
+	}()
+	run()
+	writer.Close()
+	var output strings.Builder
+	if _, err := io.Copy(&output, reader); err != nil {
+		t.Fatal(err)
+	}
+	return output.String()
+}
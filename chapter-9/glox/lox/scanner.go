@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"strconv"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Scanner struct {
@@ -139,9 +141,11 @@ func (s *Scanner) scanToken() {
 	default:
 		if s.isDigit(c) {
 			s.number()
-		} else if s.isAlpha(c) {
+		} else if r, size := utf8.DecodeRuneInString(s.source[s.start:]); isIdentifierStart(r) {
+			s.current = s.start + size
 			s.identifier()
 		} else {
+			s.current = s.start + size
 			Error(s.line, "Unexpected character.")
 		}
 		break
@@ -211,10 +215,21 @@ func (s *Scanner) string() {
 }
 
 func (s Scanner) isDigit(c rune) bool {
-	return unicode.IsDigit(c)
+	return c >= '0' && c <= '9'
 }
-func (s Scanner) isAlpha(c rune) bool {
-	return unicode.IsLetter(c) || unicode.IsDigit(c)
+
+// isIdentifierStart reports whether r can begin an identifier: a letter of
+// any script or an underscore, following Unicode's XID_Start.
+func isIdentifierStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.In(r, unicode.Nl, unicode.Other_ID_Start)
+}
+
+// isIdentifierPart reports whether r can continue an identifier: anything
+// that can begin one, a digit, or a combining mark or connector following
+// XID_Continue.
+func isIdentifierPart(r rune) bool {
+	return isIdentifierStart(r) ||
+		unicode.In(r, unicode.Nd, unicode.Mn, unicode.Mc, unicode.Pc, unicode.Other_ID_Continue)
 }
 func (s *Scanner) number() {
 	for s.isDigit(s.peek()) {
@@ -226,14 +241,25 @@ func (s *Scanner) number() {
 			s.advance()
 		}
 	}
+	// A number running straight into a name, such as 12px, is one malformed
+	// number rather than a number and a name. It still becomes a token, so
+	// the parser does not report it again as a missing expression.
+	malformed := false
+	for s.matchIdentifierPart() {
+		malformed = true
+	}
+	if malformed {
+		Error(s.line, fmt.Sprintf("Malformed number '%s'.", s.source[s.start:s.current]))
+		s.addToken(NUMBER, 0.0)
+		return
+	}
 	number, err := strconv.ParseFloat(s.source[s.start:s.current], 64)
 	if err == nil {
 		s.addToken(NUMBER, number)
 	}
 }
 func (s *Scanner) identifier() {
-	for s.isAlphaNumeric(s.peek()) {
-		s.advance()
+	for s.matchIdentifierPart() {
 	}
 	text := s.source[s.start:s.current]
 	token_type, exists := s.keywords[text]
@@ -243,6 +269,13 @@ func (s *Scanner) identifier() {
 	s.addToken(token_type)
 }
 
-func (s Scanner) isAlphaNumeric(c rune) bool {
-	return s.isAlpha(c) || s.isDigit(c)
+// matchIdentifierPart consumes the next character, which may take several
+// bytes, if it can continue an identifier.
+func (s *Scanner) matchIdentifierPart() bool {
+	r, size := utf8.DecodeRuneInString(s.source[s.current:])
+	if !isIdentifierPart(r) {
+		return false
+	}
+	s.current += size
+	return true
 }
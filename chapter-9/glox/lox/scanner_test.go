@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestScanIdentifiers checks a name starts with a letter of any script or
+// an underscore and continues with those, digits and combining marks, while
+// a leading digit begins a number instead.
+func TestScanIdentifiers(t *testing.T) {
+	type lexeme struct {
+		Type   TokenType
+		Lexeme string
+	}
+	tests := []struct {
+		source string
+		want   []lexeme
+		errors string
+	}{
+		{"my_var _private x1", []lexeme{{IDENTIFIER, "my_var"}, {IDENTIFIER, "_private"}, {IDENTIFIER, "x1"}}, ""},
+		{"café π", []lexeme{{IDENTIFIER, "café"}, {IDENTIFIER, "π"}}, ""},
+		{"e\u0301t\u00e9", []lexeme{{IDENTIFIER, "e\u0301t\u00e9"}}, ""},
+		{"12 px", []lexeme{{NUMBER, "12"}, {IDENTIFIER, "px"}}, ""},
+		{"12px", []lexeme{{NUMBER, "12px"}}, "[line 1] Error : Malformed number '12px'.\n"},
+		{"1.5_x", []lexeme{{NUMBER, "1.5_x"}}, "[line 1] Error : Malformed number '1.5_x'.\n"},
+	}
+	for _, test := range tests {
+		var tokens []Token
+		errors := captureStderr(t, func() {
+			scanner := NewScanner(test.source)
+			tokens = scanner.ScanTokens()
+		})
+		hadError = false
+		var got []lexeme
+		for _, token := range tokens {
+			if token.Type != EOF {
+				got = append(got, lexeme{token.Type, token.Lexeme})
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q scanned to %v, want %v", test.source, got, test.want)
+		}
+		if errors != test.errors {
+			t.Errorf("%q reported %q, want %q", test.source, errors, test.errors)
+		}
+	}
+}